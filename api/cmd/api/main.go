@@ -2,21 +2,32 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"feed-api/internal/app"
+	"feed-api/internal/auth"
+	"feed-api/internal/deadletter"
 	"feed-api/internal/handler"
 	"feed-api/internal/messaging"
+	"feed-api/internal/outbox"
 	"feed-api/internal/repository"
+	"feed-api/internal/transport"
+	"feed-api/internal/transport/kafka"
+	"feed-api/internal/transport/memory"
 	"feed-api/internal/worker"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	eventsToProcessTopic = "events-to-process"
 	eventsProcessedTopic = "events-processed"
 	groupID              = "message-group"
+	subscriberPrefix     = "feed-subscriber"
 	migrationsPath       = "migrations"
 )
 
@@ -35,6 +46,68 @@ func main() {
 		os.Getenv("DB_HOST"),
 		os.Getenv("DB_PORT"))
 
+	groupStrategy := handler.GroupStrategy(os.Getenv("SUBSCRIBER_GROUP_STRATEGY"))
+	if groupStrategy == "" {
+		groupStrategy = handler.GroupPerInstance
+	}
+	subscriberGroupID, err := handler.SubscriberGroupID(groupStrategy, subscriberPrefix)
+	if err != nil {
+		log.Println("Invalid subscriber configuration:", err)
+		return
+	}
+
+	slowConsumerPolicy, err := slowConsumerPolicyFromEnv()
+	if err != nil {
+		log.Println("Invalid broadcaster configuration:", err)
+		return
+	}
+
+	fanOutBatchSize, err := intFromEnv("TIMELINE_FANOUT_BATCH_SIZE", worker.DefaultFanOutBatchSize)
+	if err != nil {
+		log.Println("Invalid timeline configuration:", err)
+		return
+	}
+	fanOutThreshold, err := intFromEnv("TIMELINE_FANOUT_THRESHOLD", repository.DefaultFanOutThreshold)
+	if err != nil {
+		log.Println("Invalid timeline configuration:", err)
+		return
+	}
+
+	retryPolicy, err := retryPolicyFromEnv()
+	if err != nil {
+		log.Println("Invalid retry configuration:", err)
+		return
+	}
+
+	broker, err := transportFromEnv(brokers)
+	if err != nil {
+		log.Println("Invalid transport configuration:", err)
+		return
+	}
+
+	poolConfig, err := poolConfigFromEnv()
+	if err != nil {
+		log.Println("Invalid worker configuration:", err)
+		return
+	}
+
+	producerOptions, err := producerOptionsFromEnv()
+	if err != nil {
+		log.Println("Invalid producer configuration:", err)
+		return
+	}
+
+	authKeys, err := auth.ParseKeySet(os.Getenv("AUTH_KEYS"))
+	if err != nil {
+		log.Println("Invalid auth configuration:", err)
+		return
+	}
+	verifier, err := auth.NewVerifier(authKeys)
+	if err != nil {
+		log.Println("Invalid auth configuration:", err)
+		return
+	}
+
 	conn, err := repository.NewConnection(ctx, appDSN)
 	if err != nil {
 		cancel()
@@ -42,15 +115,54 @@ func main() {
 		return
 	}
 
-	messageProducer := messaging.NewProducer[*repository.Message](brokers, eventsProcessedTopic)
-	eventProducer := messaging.NewProducer[*repository.Message](brokers, eventsToProcessTopic)
-	messageRepository := repository.NewRepository(conn)
-	databaseProcessor := worker.NewDatabaseProcessor[*repository.Message](messageRepository, messageProducer)
-	messageWorker := worker.NewWorker[*repository.Message](brokers, eventsToProcessTopic, groupID, databaseProcessor)
+	repository.RegisterEvents(messaging.DefaultRegistry)
+	messageRepository := repository.NewRepository(conn,
+		repository.WithOutboxTopic(eventsProcessedTopic),
+	)
+	// One producer publishes every event type to events-to-process: new
+	// messages and changes to them.
+	eventProducer := messaging.NewProducer[messaging.Eventable](broker, eventsToProcessTopic,
+		append(producerOptions,
+			messaging.WithFailureHandler(func(data messaging.Eventable, err error) {
+				msg, ok := data.(*repository.Message)
+				if !ok {
+					return
+				}
+				if markErr := messageRepository.MarkFailed(context.Background(), msg, err.Error()); markErr != nil {
+					log.Println("Error recording message status:", markErr)
+				}
+			}),
+		)...,
+	)
+	expvar.Publish("event_producer", expvar.Func(func() any { return eventProducer.Stats() }))
+	outboxPublisher := outbox.NewTransportPublisher(broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey}))
+	outboxRelay := outbox.NewRelay(messageRepository, outboxPublisher, outbox.DefaultBatchSize, outbox.DefaultPollInterval)
+	timelineFanOut := worker.NewTimelineFanOut[*repository.Message](messageRepository, fanOutBatchSize, fanOutThreshold)
+	databaseProcessor := worker.NewDatabaseProcessor[*repository.Message](
+		messageRepository,
+		worker.WithFanOut[*repository.Message](timelineFanOut),
+	)
+	changeProcessor := worker.NewChangeProcessor[*repository.MessageChange](messageRepository)
+	eventRouter := worker.NewRouter()
+	worker.Route[*repository.Message](eventRouter, databaseProcessor, repository.MessageEventType)
+	worker.Route[*repository.MessageChange](eventRouter, changeProcessor, repository.MessageDeletedEventType, repository.MessageEditedEventType)
+	messageWorker := worker.NewWorker[messaging.Eventable](broker, eventsToProcessTopic, groupID, eventRouter, retryPolicy, poolConfig)
+
+	deadLetters := deadletter.NewStore[messaging.Eventable](broker, worker.DeadLetterTopic(eventsToProcessTopic), eventProducer)
+	admins := make(map[string]struct{})
+	for _, id := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			admins[id] = struct{}{}
+		}
+	}
 
-	broadcaster := handler.NewBroadcaster()
-	subscriber := handler.NewSubscriber(brokers, eventsProcessedTopic, groupID, broadcaster)
-	router := handler.NewRouter(eventProducer, messageRepository, broadcaster)
+	broadcaster, err := handler.NewBroadcaster(slowConsumerPolicy)
+	if err != nil {
+		log.Println("Invalid broadcaster configuration:", err)
+		return
+	}
+	subscriber := handler.NewSubscriber(broker, eventsProcessedTopic, subscriberGroupID, broadcaster)
+	router := handler.NewRouter(eventProducer, messageRepository, broadcaster, verifier, deadLetters, admins)
 
 	server := &http.Server{
 		Addr:    ":" + port,
@@ -62,8 +174,8 @@ func main() {
 		server,
 		messageWorker,
 		subscriber,
+		outboxRelay,
 		conn,
-		messageProducer,
 		eventProducer,
 		app.WithMigrations(migrateDSN, migrationsPath),
 	)
@@ -78,3 +190,171 @@ func main() {
 		return
 	}
 }
+
+// transportFromEnv selects the broker driver. The memory driver keeps every
+// topic inside this process, so a single API instance needs no Kafka.
+func transportFromEnv(brokers []string) (transport.Transport, error) {
+	switch driver := os.Getenv("BROKER_TRANSPORT"); driver {
+	case "", "kafka":
+		return kafka.New(brokers), nil
+	case "memory":
+		partitions, err := intFromEnv("MEMORY_TRANSPORT_PARTITIONS", memory.DefaultPartitions)
+		if err != nil {
+			return nil, err
+		}
+		return memory.New(partitions), nil
+	default:
+		return nil, fmt.Errorf("unknown broker transport %q", driver)
+	}
+}
+
+func poolConfigFromEnv() (worker.PoolConfig, error) {
+	config := worker.DefaultPoolConfig()
+
+	concurrency, err := intFromEnv("WORKER_CONCURRENCY", config.Concurrency)
+	if err != nil {
+		return config, err
+	}
+	if concurrency < 1 {
+		return config, fmt.Errorf("WORKER_CONCURRENCY must be at least 1")
+	}
+	config.Concurrency = concurrency
+
+	if raw := os.Getenv("WORKER_ORDERING"); raw != "" {
+		if config.Ordering, err = worker.ParseOrdering(raw); err != nil {
+			return config, fmt.Errorf("WORKER_ORDERING: %w", err)
+		}
+	}
+
+	if raw := os.Getenv("WORKER_DRAIN_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return config, fmt.Errorf("WORKER_DRAIN_TIMEOUT: %w", err)
+		}
+		config.DrainTimeout = d
+	}
+
+	batchSize, err := intFromEnv("WORKER_BATCH_SIZE", config.BatchSize)
+	if err != nil {
+		return config, err
+	}
+	if batchSize < 1 {
+		return config, fmt.Errorf("WORKER_BATCH_SIZE must be at least 1")
+	}
+	config.BatchSize = batchSize
+
+	if raw := os.Getenv("WORKER_BATCH_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return config, fmt.Errorf("WORKER_BATCH_TIMEOUT: %w", err)
+		}
+		config.BatchTimeout = d
+	}
+
+	return config, nil
+}
+
+func producerOptionsFromEnv() ([]messaging.ProducerOption[messaging.Eventable], error) {
+	mode := messaging.DeliverSync
+	if raw := os.Getenv("PRODUCER_DELIVERY_MODE"); raw != "" {
+		var err error
+		if mode, err = messaging.ParseDeliveryMode(raw); err != nil {
+			return nil, fmt.Errorf("PRODUCER_DELIVERY_MODE: %w", err)
+		}
+	}
+	if !mode.Durable() {
+		log.Printf("PRODUCER_DELIVERY_MODE=%s does not wait for the broker; message writes will be refused with 503", mode)
+	}
+	queueSize, err := intFromEnv("PRODUCER_RETRY_QUEUE_SIZE", messaging.DefaultRetryQueueSize)
+	if err != nil {
+		return nil, err
+	}
+	attempts, err := intFromEnv("PRODUCER_RETRY_ATTEMPTS", messaging.DefaultRetryMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if attempts < 1 {
+		return nil, fmt.Errorf("PRODUCER_RETRY_ATTEMPTS must be at least 1")
+	}
+	return []messaging.ProducerOption[messaging.Eventable]{
+		messaging.WithDeliveryMode[messaging.Eventable](mode),
+		messaging.WithRetryQueue[messaging.Eventable](queueSize, attempts),
+	}, nil
+}
+
+func slowConsumerPolicyFromEnv() (handler.BroadcasterOption, error) {
+	policy := handler.SlowConsumerPolicy(os.Getenv("SLOW_CONSUMER_POLICY"))
+	if policy == "" {
+		policy = handler.DropOldest
+	}
+
+	var maxDrops uint64 = 100
+	if raw := os.Getenv("SLOW_CONSUMER_MAX_DROPS"); raw != "" {
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("SLOW_CONSUMER_MAX_DROPS: %w", err)
+		}
+		maxDrops = n
+	}
+
+	timeout := 100 * time.Millisecond
+	if raw := os.Getenv("SLOW_CONSUMER_BLOCK_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("SLOW_CONSUMER_BLOCK_TIMEOUT: %w", err)
+		}
+		timeout = d
+	}
+
+	return handler.WithSlowConsumerPolicy(policy, maxDrops, timeout), nil
+}
+
+func intFromEnv(name string, fallback int) (int, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return n, nil
+}
+
+func retryPolicyFromEnv() (worker.RetryPolicy, error) {
+	policy := worker.DefaultRetryPolicy()
+
+	attempts, err := intFromEnv("RETRY_MAX_ATTEMPTS", policy.MaxAttempts)
+	if err != nil {
+		return policy, err
+	}
+	if attempts < 1 {
+		return policy, fmt.Errorf("RETRY_MAX_ATTEMPTS must be at least 1")
+	}
+	policy.MaxAttempts = attempts
+
+	if raw := os.Getenv("RETRY_BACKOFF"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return policy, fmt.Errorf("RETRY_BACKOFF: %w", err)
+		}
+		policy.InitialBackoff = d
+	}
+
+	if raw, ok := os.LookupEnv("RETRY_TOPIC_DELAYS"); ok {
+		policy.RetryDelays = nil
+		for _, part := range strings.Split(raw, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			d, err := time.ParseDuration(part)
+			if err != nil || d <= 0 {
+				return policy, fmt.Errorf("RETRY_TOPIC_DELAYS: invalid delay %q", part)
+			}
+			policy.RetryDelays = append(policy.RetryDelays, d)
+		}
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,149 @@
+// Command feedctl inspects and replays events parked in the dead-letter topic.
+//
+//	feedctl list [-limit N] [-from <partition>:<offset>]
+//	feedctl show <partition> <offset>
+//	feedctl replay [-all] [<partition>:<offset> ...]
+//
+// It connects to the broker given by KAFKA_HOST and KAFKA_PORT.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feed-api/internal/deadletter"
+	"feed-api/internal/messaging"
+	"feed-api/internal/repository"
+	"feed-api/internal/transport/kafka"
+	"feed-api/internal/worker"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const eventsToProcessTopic = "events-to-process"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "feedctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: feedctl <list|show|replay> [arguments]")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	brokers := []string{fmt.Sprintf("%s:%s", os.Getenv("KAFKA_HOST"), os.Getenv("KAFKA_PORT"))}
+	broker := kafka.New(brokers)
+	repository.RegisterEvents(messaging.DefaultRegistry)
+	producer := messaging.NewProducer[messaging.Eventable](broker, eventsToProcessTopic)
+	defer producer.Close()
+	store := deadletter.NewStore[messaging.Eventable](broker, worker.DeadLetterTopic(eventsToProcessTopic), producer)
+
+	switch args[0] {
+	case "list":
+		return list(ctx, store, args[1:])
+	case "show":
+		return show(ctx, store, args[1:])
+	case "replay":
+		return replay(ctx, store, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func list(ctx context.Context, store *deadletter.Store[messaging.Eventable], args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	limit := flags.Int("limit", deadletter.DefaultListLimit, "maximum number of events to list")
+	fromFlag := flags.String("from", "", "position <partition>:<offset> to list from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var from deadletter.Position
+	if *fromFlag != "" {
+		var err error
+		if from, err = deadletter.ParsePosition(*fromFlag); err != nil {
+			return err
+		}
+	}
+
+	entries, next, err := store.List(ctx, from, *limit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POSITION\tEVENT\tATTEMPTS\tFAILED AT\tSOURCE\tERROR")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d:%d\t%s\t%d\t%s\t%s/%d/%d\t%s\n",
+			e.Partition, e.Offset, e.EventID, e.Attempts, e.FailedAt.Format(time.RFC3339),
+			e.SourceTopic, e.SourcePartition, e.SourceOffset, e.Error)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if next != nil {
+		fmt.Printf("More events: feedctl list -from %s\n", next)
+	}
+	return nil
+}
+
+func show(ctx context.Context, store *deadletter.Store[messaging.Eventable], args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: feedctl show <partition> <offset>")
+	}
+	pos, err := deadletter.ParsePosition(args[0] + ":" + args[1])
+	if err != nil {
+		return err
+	}
+
+	entry, err := store.Get(ctx, pos)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entry)
+}
+
+func replay(ctx context.Context, store *deadletter.Store[messaging.Eventable], args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	all := flags.Bool("all", false, "replay every dead-lettered event")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *all == (flags.NArg() > 0) {
+		return errors.New("usage: feedctl replay [-all] [<partition>:<offset> ...]")
+	}
+
+	var (
+		replayed int
+		err      error
+	)
+	if *all {
+		replayed, err = store.ReplayAll(ctx)
+	} else {
+		positions := make([]deadletter.Position, 0, flags.NArg())
+		for _, arg := range flags.Args() {
+			pos, parseErr := deadletter.ParsePosition(arg)
+			if parseErr != nil {
+				return parseErr
+			}
+			positions = append(positions, pos)
+		}
+		replayed, err = store.Replay(ctx, positions)
+	}
+
+	fmt.Printf("Replayed %d event(s)\n", replayed)
+	return err
+}
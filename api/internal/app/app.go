@@ -3,11 +3,13 @@ package app
 import (
 	"context"
 	"errors"
+	"feed-api/internal/messaging"
 	"feed-api/internal/repository"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -29,14 +31,15 @@ func WithMigrations(dsn, migrationsPath string) Option {
 }
 
 type Application struct {
-	server          *http.Server
-	worker          Worker
-	subscriber      Subscriber
-	ctx             context.Context
-	cancel          context.CancelFunc
-	conn            *pgxpool.Pool
-	messageProducer Producer[*repository.Message]
-	eventProducer   Producer[*repository.Message]
+	server        *http.Server
+	worker        Worker
+	subscriber    Subscriber
+	relay         Relay
+	ctx           context.Context
+	cancel        context.CancelFunc
+	conn          *pgxpool.Pool
+	eventProducer Producer[messaging.Eventable]
+	wg            sync.WaitGroup
 }
 
 func NewApplication(
@@ -45,20 +48,21 @@ func NewApplication(
 	server *http.Server,
 	worker Worker,
 	subscriber Subscriber,
+	relay Relay,
 	conn *pgxpool.Pool,
-	messageProducer, eventProducer Producer[*repository.Message],
+	eventProducer Producer[messaging.Eventable],
 	options ...Option,
 ) (*Application, error) {
 
 	app := &Application{
-		server:          server,
-		worker:          worker,
-		subscriber:      subscriber,
-		conn:            conn,
-		messageProducer: messageProducer,
-		eventProducer:   eventProducer,
-		ctx:             ctx,
-		cancel:          cancel,
+		server:        server,
+		worker:        worker,
+		subscriber:    subscriber,
+		relay:         relay,
+		conn:          conn,
+		eventProducer: eventProducer,
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 
 	for _, opt := range options {
@@ -74,6 +78,13 @@ func (a *Application) Start() error {
 	go a.worker.Start(a.ctx)
 	go a.subscriber.Run(a.ctx)
 
+	// The relay uses the connection pool, so it must stop before it is closed.
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.relay.Run(a.ctx)
+	}()
+
 	go func() {
 		log.Printf("Listening on port %s", a.server.Addr)
 		if err := a.server.ListenAndServe(); err != nil {
@@ -99,7 +110,7 @@ func (a *Application) awaitShutdown() error {
 	if err != nil && errors.Is(err, http.ErrServerClosed) {
 		log.Println("Server shutdown completed successfully")
 	}
-	a.messageProducer.Close()
+	a.wg.Wait()
 	a.eventProducer.Close()
 	a.conn.Close()
 
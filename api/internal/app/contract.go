@@ -13,6 +13,10 @@ type Subscriber interface {
 	Run(ctx context.Context)
 }
 
+type Relay interface {
+	Run(ctx context.Context)
+}
+
 type Producer[T any] interface {
 	Publish(ctx context.Context, data T) error
 	Close() error
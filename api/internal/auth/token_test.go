@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// sign builds an HS256 token, or a token with the given alg and an empty
+// signature for algorithms the verifier must not accept.
+func sign(t *testing.T, key []byte, header, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	unsigned := encode(header) + "." + encode(claims)
+	if header["alg"] != "HS256" {
+		return unsigned + "."
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	current, previous := []byte("current-secret"), []byte("previous-secret")
+	verifier, err := NewVerifier(map[string][]byte{"current": current, "previous": previous})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.now = func() time.Time { return testNow }
+
+	header := map[string]any{"alg": "HS256", "kid": "current"}
+	valid := map[string]any{"sub": "user-1", "exp": testNow.Add(time.Hour).Unix()}
+	with := func(base map[string]any, key string, value any) map[string]any {
+		out := make(map[string]any, len(base)+1)
+		for k, v := range base {
+			out[k] = v
+		}
+		if value == nil {
+			delete(out, key)
+		} else {
+			out[key] = value
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", sign(t, current, header, valid), nil},
+		{"rotated key", sign(t, previous, with(header, "kid", "previous"), valid), nil},
+		{"bad signature", sign(t, previous, header, valid), ErrBadSignature},
+		{"unknown kid", sign(t, current, with(header, "kid", "retired"), valid), ErrUnknownKey},
+		{"no kid with several keys", sign(t, current, with(header, "kid", nil), valid), ErrUnknownKey},
+		{"alg none", sign(t, current, with(header, "alg", "none"), valid), ErrMalformedToken},
+		{"alg RS256", sign(t, current, with(header, "alg", "RS256"), valid), ErrMalformedToken},
+		{"expired", sign(t, current, header, with(valid, "exp", testNow.Add(-time.Minute).Unix())), ErrTokenExpired},
+		{"expired within leeway", sign(t, current, header, with(valid, "exp", testNow.Add(-leeway/2).Unix())), nil},
+		{"missing exp", sign(t, current, header, with(valid, "exp", nil)), ErrMissingExpiry},
+		{"not yet valid", sign(t, current, header, with(valid, "nbf", testNow.Add(time.Minute).Unix())), ErrTokenNotActive},
+		{"nbf within leeway", sign(t, current, header, with(valid, "nbf", testNow.Add(leeway/2).Unix())), nil},
+		{"missing sub", sign(t, current, header, with(valid, "sub", nil)), ErrMissingSubject},
+		{"two segments", "header.claims", ErrMalformedToken},
+		{"header not JSON", base64.RawURLEncoding.EncodeToString([]byte("not-json")) + ".e30.c2ln", ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := verifier.Verify(tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && principal.UserID != "user-1" {
+				t.Fatalf("got principal %q, want user-1", principal.UserID)
+			}
+		})
+	}
+}
+
+func TestVerifyAcceptsNoKidWithSingleKey(t *testing.T) {
+	key := []byte("only-secret")
+	verifier, err := NewVerifier(map[string][]byte{"only": key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.now = func() time.Time { return testNow }
+
+	token := sign(t, key, map[string]any{"alg": "HS256"}, map[string]any{"sub": "user-1", "exp": testNow.Add(time.Hour).Unix()})
+	if _, err = verifier.Verify(token); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	keys, err := ParseKeySet("a:" + base64.StdEncoding.EncodeToString([]byte("one")) + ", b:" + base64.StdEncoding.EncodeToString([]byte("two")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(keys["a"]) != "one" || string(keys["b"]) != "two" {
+		t.Fatalf("got %q", keys)
+	}
+
+	for _, raw := range []string{"no-secret", ":c2VjcmV0", "a:not base64"} {
+		if _, err = ParseKeySet(raw); err == nil {
+			t.Errorf("ParseKeySet(%q) succeeded, want an error", raw)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feed-api/internal/messaging"
+	"feed-api/internal/transport"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultListLimit is the page size List uses when it is given none.
+const DefaultListLimit = 100
+
+var ErrNotFound = errors.New("dead-lettered event not found")
+
+type Replayer[T messaging.Eventable] interface {
+	PublishReplay(ctx context.Context, data T, replayOf string) error
+}
+
+// Entry is an event parked in the dead-letter topic together with the reason
+// it failed.
+type Entry struct {
+	Partition       int             `json:"partition"`
+	Offset          int64           `json:"offset"`
+	EventID         string          `json:"event_id,omitempty"`
+	Error           string          `json:"error"`
+	Attempts        int             `json:"attempts"`
+	FailedAt        time.Time       `json:"failed_at"`
+	SourceTopic     string          `json:"source_topic"`
+	SourcePartition int             `json:"source_partition"`
+	SourceOffset    int64           `json:"source_offset"`
+	Payload         json.RawMessage `json:"payload,omitempty"`
+}
+
+// Position identifies an entry in the dead-letter topic.
+type Position struct {
+	Partition int   `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+// ParsePosition parses a position written as <partition>:<offset>.
+func ParsePosition(raw string) (Position, error) {
+	partition, offset, ok := strings.Cut(raw, ":")
+	if !ok {
+		return Position{}, fmt.Errorf("invalid position %q, expected <partition>:<offset>", raw)
+	}
+	p, err := strconv.Atoi(partition)
+	if err != nil || p < 0 {
+		return Position{}, fmt.Errorf("invalid partition %q", partition)
+	}
+	o, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil || o < 0 {
+		return Position{}, fmt.Errorf("invalid offset %q", offset)
+	}
+	return Position{Partition: p, Offset: o}, nil
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Partition, p.Offset)
+}
+
+// Broker is what the store needs from the transport: direct reads of the
+// dead-letter topic, and a consumer group to remember how far ReplayAll got.
+type Broker interface {
+	transport.Inspector
+	transport.GroupOffsets
+}
+
+// Store reads the dead-letter topic and replays its events back onto the
+// topic they came from through the regular producer.
+type Store[T messaging.Eventable] struct {
+	broker   Broker
+	topic    string
+	replayer Replayer[T]
+}
+
+func NewStore[T messaging.Eventable](broker Broker, topic string, replayer Replayer[T]) *Store[T] {
+	return &Store[T]{
+		broker:   broker,
+		topic:    topic,
+		replayer: replayer,
+	}
+}
+
+// ReplayGroupID is the consumer group whose committed offsets mark how far
+// ReplayAll has replayed each partition of the dead-letter topic.
+func ReplayGroupID(topic string) string {
+	return topic + "-replay"
+}
+
+// List returns up to limit entries from position from on, ordered by
+// partition and offset, without their payloads. A limit of zero or less lists
+// DefaultListLimit entries. The zero position lists from the start of the
+// topic. next is where the following page starts, or nil once the topic is
+// exhausted.
+func (s *Store[T]) List(ctx context.Context, from Position, limit int) (entries []Entry, next *Position, err error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	start := func(partition int) int64 {
+		switch {
+		case partition < from.Partition:
+			return math.MaxInt64
+		case partition == from.Partition:
+			return from.Offset
+		default:
+			return 0
+		}
+	}
+	err = s.scan(ctx, start, func(msg transport.Message) bool {
+		entry := newEntry(msg)
+		entry.Payload = nil
+		entries = append(entries, entry)
+		return len(entries) < limit
+	})
+	if err != nil || len(entries) < limit {
+		return entries, nil, err
+	}
+	last := entries[len(entries)-1]
+	return entries, &Position{Partition: last.Partition, Offset: last.Offset + 1}, nil
+}
+
+func (s *Store[T]) Get(ctx context.Context, pos Position) (*Entry, error) {
+	msg, err := s.read(ctx, pos)
+	if err != nil {
+		return nil, err
+	}
+	entry := newEntry(msg)
+	return &entry, nil
+}
+
+// Replay republishes the entries at the given positions.
+func (s *Store[T]) Replay(ctx context.Context, positions []Position) (int, error) {
+	replayed := 0
+	for _, pos := range positions {
+		msg, err := s.read(ctx, pos)
+		if err != nil {
+			return replayed, fmt.Errorf("read %d/%d: %w", pos.Partition, pos.Offset, err)
+		}
+		if err = s.replay(ctx, msg); err != nil {
+			return replayed, fmt.Errorf("replay %d/%d: %w", pos.Partition, pos.Offset, err)
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// ReplayAll republishes every entry that a previous ReplayAll has not. Its
+// progress is committed as the offsets of the ReplayGroupID consumer group,
+// also when it stops at an error, so the next call resumes with the entry
+// that failed. Entries replayed one by one with Replay are not tracked.
+func (s *Store[T]) ReplayAll(ctx context.Context) (int, error) {
+	committed, err := s.broker.CommittedOffsets(ctx, ReplayGroupID(s.topic), s.topic)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	progress := make(map[int]int64)
+	var replayErr error
+	err = s.scan(ctx, func(partition int) int64 { return committed[partition] }, func(msg transport.Message) bool {
+		if replayErr = s.replay(ctx, msg); replayErr != nil {
+			replayErr = fmt.Errorf("replay %d/%d: %w", msg.Partition, msg.Offset, replayErr)
+			return false
+		}
+		progress[msg.Partition] = msg.Offset + 1
+		replayed++
+		return true
+	})
+	if len(progress) > 0 {
+		// The caller's context may be what stopped the scan.
+		commitCtx := context.WithoutCancel(ctx)
+		if commitErr := s.broker.CommitOffsets(commitCtx, ReplayGroupID(s.topic), s.topic, progress); commitErr != nil {
+			return replayed, errors.Join(err, replayErr, fmt.Errorf("commit replay progress: %w", commitErr))
+		}
+	}
+	if err != nil {
+		return replayed, err
+	}
+	return replayed, replayErr
+}
+
+func (s *Store[T]) replay(ctx context.Context, msg transport.Message) error {
+	var event messaging.Event[T]
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return fmt.Errorf("decode event: %w", err)
+	}
+	replayOf := fmt.Sprintf("%s/%d/%d", s.topic, msg.Partition, msg.Offset)
+	return s.replayer.PublishReplay(ctx, event.Data(), replayOf)
+}
+
+// scan calls fn for every message in the topic, in partition order, until fn
+// returns false. Each partition is read from the offset start returns for it,
+// or from its first retained message if that is later.
+func (s *Store[T]) scan(ctx context.Context, start func(partition int) int64, fn func(msg transport.Message) bool) error {
+	partitions, err := s.broker.Partitions(ctx, s.topic)
+	if err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		first, last, err := s.broker.Offsets(ctx, s.topic, partition)
+		if err != nil {
+			return err
+		}
+		first = max(first, start(partition))
+		if first >= last {
+			continue
+		}
+		stopped := false
+		err = s.broker.Read(ctx, s.topic, partition, first, last, func(msg transport.Message) bool {
+			stopped = !fn(msg)
+			return !stopped
+		})
+		if err != nil || stopped {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store[T]) read(ctx context.Context, pos Position) (transport.Message, error) {
+	first, last, err := s.broker.Offsets(ctx, s.topic, pos.Partition)
+	if err != nil {
+		return transport.Message{}, err
+	}
+	if pos.Offset < first || pos.Offset >= last {
+		return transport.Message{}, ErrNotFound
+	}
+
+	var (
+		msg   transport.Message
+		found bool
+	)
+	err = s.broker.Read(ctx, s.topic, pos.Partition, pos.Offset, pos.Offset+1, func(m transport.Message) bool {
+		msg, found = m, m.Offset == pos.Offset
+		return false
+	})
+	if err != nil {
+		return transport.Message{}, err
+	}
+	if !found {
+		return transport.Message{}, ErrNotFound
+	}
+	return msg, nil
+}
+
+func newEntry(msg transport.Message) Entry {
+	entry := Entry{
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Payload:   json.RawMessage(msg.Value),
+	}
+	if !json.Valid(msg.Value) {
+		entry.Payload, _ = json.Marshal(string(msg.Value))
+	}
+
+	var envelope struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(msg.Value, &envelope) == nil {
+		entry.EventID = envelope.ID
+	}
+
+	for _, h := range msg.Headers {
+		value := string(h.Value)
+		switch h.Key {
+		case messaging.HeaderError:
+			entry.Error = value
+		case messaging.HeaderAttempts:
+			entry.Attempts, _ = strconv.Atoi(value)
+		case messaging.HeaderFailedAt:
+			entry.FailedAt, _ = time.Parse(time.RFC3339Nano, value)
+		case messaging.HeaderSourceTopic:
+			entry.SourceTopic = value
+		case messaging.HeaderSourcePartition:
+			entry.SourcePartition, _ = strconv.Atoi(value)
+		case messaging.HeaderSourceOffset:
+			entry.SourceOffset, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return entry
+}
@@ -0,0 +1,183 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feed-api/internal/messaging"
+	"feed-api/internal/transport"
+	"feed-api/internal/transport/memory"
+	"slices"
+	"testing"
+)
+
+const testTopic = "events-dlq"
+
+type testEvent struct {
+	Text string `json:"text"`
+}
+
+func (e *testEvent) MarshalJSON() ([]byte, error) {
+	type plain testEvent
+	return json.Marshal((*plain)(e))
+}
+
+// fakeReplayer records the replayed events and fails those whose text is in
+// fail.
+type fakeReplayer struct {
+	replayed []string
+	fail     map[string]bool
+}
+
+func (r *fakeReplayer) PublishReplay(ctx context.Context, data *testEvent, replayOf string) error {
+	if r.fail[data.Text] {
+		return errors.New("broker unavailable")
+	}
+	r.replayed = append(r.replayed, data.Text)
+	return nil
+}
+
+// deadLetter publishes events to the dead-letter topic, round robin across
+// its partitions.
+func deadLetter(t *testing.T, broker *memory.Transport, texts ...string) {
+	t.Helper()
+	publisher := broker.NewPublisher(transport.PublisherConfig{})
+	for _, text := range texts {
+		value, err := json.Marshal(messaging.NewEventMessage(&testEvent{Text: text}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = publisher.Publish(context.Background(), transport.Message{Topic: testTopic, Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListPagesThroughPartitions(t *testing.T) {
+	broker := memory.New(2)
+	// Partition 0 gets a, c and e; partition 1 gets b and d.
+	deadLetter(t, broker, "a", "b", "c", "d", "e")
+	store := NewStore[*testEvent](broker, testTopic, &fakeReplayer{})
+
+	var (
+		got  []string
+		from Position
+	)
+	for page := 0; ; page++ {
+		entries, next, err := store.List(context.Background(), from, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.Payload != nil {
+				t.Fatalf("entry %d:%d has a payload", e.Partition, e.Offset)
+			}
+			got = append(got, Position{Partition: e.Partition, Offset: e.Offset}.String())
+		}
+		if next == nil {
+			break
+		}
+		if page > 3 {
+			t.Fatal("listing did not end")
+		}
+		from = *next
+	}
+
+	want := []string{"0:0", "0:1", "0:2", "1:0", "1:1"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestListWithoutLimitUsesDefault(t *testing.T) {
+	broker := memory.New(2)
+	deadLetter(t, broker, "a", "b", "c")
+	store := NewStore[*testEvent](broker, testTopic, &fakeReplayer{})
+
+	for _, limit := range []int{0, -1} {
+		entries, next, err := store.List(context.Background(), Position{}, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 3 || next != nil {
+			t.Fatalf("limit %d: got %d entries and next %v, want all 3 and no next page", limit, len(entries), next)
+		}
+	}
+}
+
+func TestReplayAllSkipsEventsReplayedBefore(t *testing.T) {
+	broker := memory.New(2)
+	deadLetter(t, broker, "a", "b")
+	replayer := &fakeReplayer{}
+	store := NewStore[*testEvent](broker, testTopic, replayer)
+
+	for i, want := range []int{2, 0} {
+		replayed, err := store.ReplayAll(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayed != want {
+			t.Fatalf("call %d replayed %d events, want %d", i, replayed, want)
+		}
+	}
+
+	deadLetter(t, broker, "c")
+	if replayed, err := store.ReplayAll(context.Background()); err != nil || replayed != 1 {
+		t.Fatalf("got %d, %v after a new dead letter, want 1", replayed, err)
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(replayer.replayed, want) {
+		t.Fatalf("replayed %v, want %v", replayer.replayed, want)
+	}
+}
+
+func TestReplayAllResumesFromFailedEvent(t *testing.T) {
+	broker := memory.New(1)
+	deadLetter(t, broker, "a", "b", "c")
+	replayer := &fakeReplayer{fail: map[string]bool{"b": true}}
+	store := NewStore[*testEvent](broker, testTopic, replayer)
+
+	replayed, err := store.ReplayAll(context.Background())
+	if err == nil || replayed != 1 {
+		t.Fatalf("got %d, %v, want 1 replayed and an error", replayed, err)
+	}
+	offsets, err := broker.CommittedOffsets(context.Background(), ReplayGroupID(testTopic), testTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offsets[0] != 1 {
+		t.Fatalf("committed offset %d, want 1", offsets[0])
+	}
+
+	replayer.fail = nil
+	if replayed, err = store.ReplayAll(context.Background()); err != nil || replayed != 2 {
+		t.Fatalf("got %d, %v on retry, want 2", replayed, err)
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(replayer.replayed, want) {
+		t.Fatalf("replayed %v, want %v", replayer.replayed, want)
+	}
+}
+
+func TestReplayReportsMissingPositions(t *testing.T) {
+	broker := memory.New(1)
+	deadLetter(t, broker, "a")
+	replayer := &fakeReplayer{}
+	store := NewStore[*testEvent](broker, testTopic, replayer)
+
+	replayed, err := store.Replay(context.Background(), []Position{{0, 0}, {0, 5}})
+	if !errors.Is(err, ErrNotFound) || replayed != 1 {
+		t.Fatalf("got %d, %v, want 1 replayed and ErrNotFound", replayed, err)
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	for raw, valid := range map[string]bool{"0:12": true, "3:0": true, "12": false, "-1:0": false, "0:x": false} {
+		pos, err := ParsePosition(raw)
+		if (err == nil) != valid {
+			t.Errorf("ParsePosition(%q): got error %v", raw, err)
+			continue
+		}
+		if valid && pos.String() != raw {
+			t.Errorf("ParsePosition(%q) = %s", raw, pos)
+		}
+	}
+}
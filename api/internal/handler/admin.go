@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"feed-api/internal/deadletter"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type AdminHandler struct {
+	deadLetters DeadLetterStore
+}
+
+func NewAdminHandler(deadLetters DeadLetterStore) *AdminHandler {
+	return &AdminHandler{
+		deadLetters: deadLetters,
+	}
+}
+
+func (a *AdminHandler) ListDeadLetters(rw http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(rw, badRequest("limit", "Limit must be a positive integer"))
+		return
+	}
+
+	var from deadletter.Position
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		if from, err = deadletter.ParsePosition(raw); err != nil {
+			writeError(rw, badRequest("from", "From must be a position <partition>:<offset>"))
+			return
+		}
+	}
+
+	entries, next, err := a.deadLetters.List(r.Context(), from, limit)
+	if err != nil {
+		log.Println("Error listing dead letters:", err)
+		writeError(rw, internalError("Failed to list dead-lettered events"))
+		return
+	}
+
+	type ListDeadLettersResponse struct {
+		Events []deadletter.Entry `json:"events"`
+		Next   string             `json:"next,omitempty"`
+	}
+	response := ListDeadLettersResponse{Events: entries}
+	if next != nil {
+		response.Next = next.String()
+	}
+	writeJSON(rw, http.StatusOK, response)
+}
+
+func (a *AdminHandler) GetDeadLetter(rw http.ResponseWriter, r *http.Request) {
+	partition, err := strconv.Atoi(r.PathValue("partition"))
+	if err != nil || partition < 0 {
+		writeError(rw, badRequest("partition", "Partition must be a non-negative integer"))
+		return
+	}
+	offset, err := strconv.ParseInt(r.PathValue("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		writeError(rw, badRequest("offset", "Offset must be a non-negative integer"))
+		return
+	}
+
+	entry, err := a.deadLetters.Get(r.Context(), deadletter.Position{Partition: partition, Offset: offset})
+	if errors.Is(err, deadletter.ErrNotFound) {
+		writeError(rw, notFound("Dead-lettered event not found"))
+		return
+	}
+	if err != nil {
+		log.Println("Error reading dead letter:", err)
+		writeError(rw, internalError("Failed to read dead-lettered event"))
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, entry)
+}
+
+func (a *AdminHandler) ReplayDeadLetters(rw http.ResponseWriter, r *http.Request) {
+	type ReplayRequest struct {
+		Events []deadletter.Position `json:"events"`
+		All    bool                  `json:"all"`
+	}
+	var request ReplayRequest
+	if apiErr := decodeJSON(rw, r, &request); apiErr != nil {
+		writeError(rw, apiErr)
+		return
+	}
+	if request.All == (len(request.Events) > 0) {
+		writeError(rw, validationFailed("events", "Provide either events or all, but not both"))
+		return
+	}
+
+	var (
+		replayed int
+		err      error
+	)
+	if request.All {
+		replayed, err = a.deadLetters.ReplayAll(r.Context())
+	} else {
+		replayed, err = a.deadLetters.Replay(r.Context(), request.Events)
+	}
+
+	if errors.Is(err, deadletter.ErrNotFound) {
+		writeError(rw, notFound(fmt.Sprintf("Dead-lettered event not found, %d event(s) replayed before it", replayed)))
+		return
+	}
+	if err != nil {
+		log.Println("Error replaying dead letters:", err)
+		writeError(rw, internalError(fmt.Sprintf("Failed to replay dead-lettered events, %d event(s) replayed before the failure", replayed)))
+		return
+	}
+
+	type ReplayResponse struct {
+		Replayed int `json:"replayed"`
+	}
+	writeJSON(rw, http.StatusOK, ReplayResponse{Replayed: replayed})
+}
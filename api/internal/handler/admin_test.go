@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feed-api/internal/deadletter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeDeadLetters holds entries in listing order. Replay fails with err
+// after replaying replayed events.
+type fakeDeadLetters struct {
+	entries  []deadletter.Entry
+	replayed int
+	err      error
+}
+
+func (s *fakeDeadLetters) List(ctx context.Context, from deadletter.Position, limit int) ([]deadletter.Entry, *deadletter.Position, error) {
+	var entries []deadletter.Entry
+	for _, e := range s.entries {
+		if e.Partition > from.Partition || (e.Partition == from.Partition && e.Offset >= from.Offset) {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) <= limit {
+		return entries, nil, nil
+	}
+	next := &deadletter.Position{Partition: entries[limit].Partition, Offset: entries[limit].Offset}
+	return entries[:limit], next, nil
+}
+
+func (s *fakeDeadLetters) Get(ctx context.Context, pos deadletter.Position) (*deadletter.Entry, error) {
+	return nil, deadletter.ErrNotFound
+}
+
+func (s *fakeDeadLetters) Replay(ctx context.Context, positions []deadletter.Position) (int, error) {
+	return s.replayed, s.err
+}
+
+func (s *fakeDeadLetters) ReplayAll(ctx context.Context) (int, error) {
+	return s.replayed, s.err
+}
+
+func TestListDeadLettersPages(t *testing.T) {
+	store := &fakeDeadLetters{entries: []deadletter.Entry{
+		{Partition: 0, Offset: 4}, {Partition: 0, Offset: 5}, {Partition: 1, Offset: 0},
+	}}
+	handler := NewAdminHandler(store)
+
+	type page struct {
+		Events []deadletter.Entry `json:"events"`
+		Next   string             `json:"next"`
+	}
+	list := func(target string) page {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ListDeadLetters(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d: %s", target, rec.Code, rec.Body)
+		}
+		var p page
+		if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	first := list("/api/admin/dlq?limit=2")
+	if len(first.Events) != 2 || first.Next != "1:0" {
+		t.Fatalf("got %d events and next %q, want 2 and 1:0", len(first.Events), first.Next)
+	}
+	second := list("/api/admin/dlq?limit=2&from=" + first.Next)
+	if len(second.Events) != 1 || second.Next != "" {
+		t.Fatalf("got %d events and next %q, want the last event", len(second.Events), second.Next)
+	}
+
+	for _, target := range []string{"/api/admin/dlq?from=1", "/api/admin/dlq?limit=0", "/api/admin/dlq?limit=-1"} {
+		rec := httptest.NewRecorder()
+		handler.ListDeadLetters(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: got status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestReplayDeadLettersErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"missing event", deadletter.ErrNotFound, http.StatusNotFound, codeNotFound},
+		{"broker failure", errors.New("broker unavailable"), http.StatusInternalServerError, codeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminHandler(&fakeDeadLetters{replayed: 1, err: tt.err})
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/dlq/replay", strings.NewReader(`{"all":true}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ReplayDeadLetters(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if code := decodeErrorCode(t, rec); code != tt.wantCode {
+				t.Fatalf("got error code %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
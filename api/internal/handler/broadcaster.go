@@ -2,46 +2,236 @@ package handler
 
 import (
 	"feed-api/internal/messaging"
-	"feed-api/internal/repository"
+	"feed-api/internal/tags"
+	"fmt"
 	"log"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+const clientBufferSize = 10
+
+// SlowConsumerPolicy decides what the Broadcaster does when a client's buffer is full.
+type SlowConsumerPolicy string
+
+const (
+	// DropOldest discards the oldest buffered event to make room for the new one.
+	DropOldest SlowConsumerPolicy = "drop-oldest"
+	// DisconnectAfterDrops discards the new event and disconnects the client
+	// once it has missed maxDrops events.
+	DisconnectAfterDrops SlowConsumerPolicy = "disconnect"
+	// BlockWithTimeout waits up to blockTimeout for buffer space before
+	// discarding the event.
+	BlockWithTimeout SlowConsumerPolicy = "block"
+)
+
+type BroadcasterOption func(*Broadcaster) error
+
+// WithSlowConsumerPolicy configures the policy. maxDrops is used by
+// DisconnectAfterDrops and timeout by BlockWithTimeout.
+func WithSlowConsumerPolicy(policy SlowConsumerPolicy, maxDrops uint64, timeout time.Duration) BroadcasterOption {
+	return func(b *Broadcaster) error {
+		switch policy {
+		case DropOldest:
+		case DisconnectAfterDrops:
+			if maxDrops == 0 {
+				return fmt.Errorf("policy %q requires a positive drop limit", policy)
+			}
+		case BlockWithTimeout:
+			if timeout <= 0 {
+				return fmt.Errorf("policy %q requires a positive timeout", policy)
+			}
+		default:
+			return fmt.Errorf("unknown slow consumer policy %q", policy)
+		}
+		b.policy = policy
+		b.maxDrops = maxDrops
+		b.blockTimeout = timeout
+		return nil
+	}
+}
+
+// MessageFilter reports whether a client wants to receive the event carrying
+// data: a message, or a change to one.
+type MessageFilter func(data messaging.Eventable) bool
+
+// AuthorFilter accepts messages, and changes to messages, written by any of
+// the given users.
+func AuthorFilter(userIDs []string) MessageFilter {
+	authors := make(map[string]struct{}, len(userIDs))
+	for _, id := range userIDs {
+		authors[id] = struct{}{}
+	}
+	return func(data messaging.Eventable) bool {
+		authored, ok := data.(interface{ UserID() string })
+		if !ok {
+			return false
+		}
+		_, ok = authors[authored.UserID()]
+		return ok
+	}
+}
+
+// TagFilter accepts messages whose content has the given normalized hashtag,
+// and every change to and stats of a message. The tag a message had when the
+// client was sent it is not known here, so the feed handler drops changes to
+// messages it did not send.
+func TagFilter(tag string) MessageFilter {
+	return func(data messaging.Eventable) bool {
+		switch data := data.(type) {
+		case interface{ MessageID() string }:
+			return true
+		case interface{ Content() string }:
+			return slices.Contains(tags.Extract(data.Content()).Hashtags, tag)
+		default:
+			return false
+		}
+	}
+}
+
+// Client is a feed connection registered with the Broadcaster.
+type Client struct {
+	events  chan *messaging.Event[messaging.Eventable]
+	gaps    chan struct{}
+	filter  MessageFilter
+	dropped atomic.Uint64
+}
+
+// NewClient creates a client receiving the events accepted by filter, or all
+// events if filter is nil.
+func NewClient(filter MessageFilter) *Client {
+	return &Client{
+		events: make(chan *messaging.Event[messaging.Eventable], clientBufferSize),
+		gaps:   make(chan struct{}, 1),
+		filter: filter,
+	}
+}
+
+func (c *Client) accepts(msg *messaging.Event[messaging.Eventable]) bool {
+	return c.filter == nil || c.filter(msg.Data())
+}
+
+// Events is closed when the client is unregistered or disconnected.
+func (c *Client) Events() <-chan *messaging.Event[messaging.Eventable] {
+	return c.events
+}
+
+// Gaps receives a signal after one or more events were dropped for this client.
+func (c *Client) Gaps() <-chan struct{} {
+	return c.gaps
+}
+
+// Dropped returns how many events this client has missed.
+func (c *Client) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+func (c *Client) drop() uint64 {
+	n := c.dropped.Add(1)
+	select {
+	case c.gaps <- struct{}{}:
+	default:
+	}
+	return n
+}
+
 type Broadcaster struct {
-	mu      sync.RWMutex
-	clients map[chan *messaging.Event[*repository.Message]]struct{}
+	mu           sync.RWMutex
+	clients      map[*Client]struct{}
+	policy       SlowConsumerPolicy
+	maxDrops     uint64
+	blockTimeout time.Duration
 }
 
-func NewBroadcaster() *Broadcaster {
-	return &Broadcaster{
-		clients: make(map[chan *messaging.Event[*repository.Message]]struct{}),
+func NewBroadcaster(options ...BroadcasterOption) (*Broadcaster, error) {
+	b := &Broadcaster{
+		clients: make(map[*Client]struct{}),
+		policy:  DropOldest,
+	}
+
+	for _, opt := range options {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
 	}
+
+	return b, nil
 }
 
-func (b *Broadcaster) Register(client chan *messaging.Event[*repository.Message]) {
+func (b *Broadcaster) Register(client *Client) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	b.clients[client] = struct{}{}
 	log.Println("Client registered. Total clients:", len(b.clients))
 }
 
-func (b *Broadcaster) Unregister(client chan *messaging.Event[*repository.Message]) {
+func (b *Broadcaster) Unregister(client *Client) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	if _, ok := b.clients[client]; ok {
 		delete(b.clients, client)
-		close(client)
-		log.Println("Client unregistered. Total clients:", len(b.clients))
+		close(client.events)
+		log.Printf("Client unregistered. Dropped events: %d. Total clients: %d", client.Dropped(), len(b.clients))
 	}
 }
 
-func (b *Broadcaster) Broadcast(msg *messaging.Event[*repository.Message]) {
+// Broadcast delivers msg to every client, applying the slow consumer policy
+// to clients whose buffer is full. With BlockWithTimeout a slow client delays
+// delivery to the clients after it by up to the timeout.
+func (b *Broadcaster) Broadcast(msg *messaging.Event[messaging.Eventable]) {
+	var disconnect []*Client
+
 	b.mu.RLock()
-	defer b.mu.RUnlock()
 	for client := range b.clients {
+		if !client.accepts(msg) {
+			continue
+		}
+		if !b.deliver(client, msg) {
+			disconnect = append(disconnect, client)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, client := range disconnect {
+		log.Println("Disconnecting slow client")
+		b.Unregister(client)
+	}
+}
+
+// deliver reports false when the client should be disconnected.
+func (b *Broadcaster) deliver(client *Client, msg *messaging.Event[messaging.Eventable]) bool {
+	select {
+	case client.events <- msg:
+		return true
+	default:
+	}
+
+	switch b.policy {
+	case DropOldest:
+		select {
+		case <-client.events:
+			client.drop()
+		default:
+		}
 		select {
-		case client <- msg:
+		case client.events <- msg:
 		default:
+			client.drop()
+		}
+	case DisconnectAfterDrops:
+		if client.drop() >= b.maxDrops {
+			return false
+		}
+	case BlockWithTimeout:
+		timer := time.NewTimer(b.blockTimeout)
+		defer timer.Stop()
+		select {
+		case client.events <- msg:
+		case <-timer.C:
+			client.drop()
 		}
 	}
+	return true
 }
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"feed-api/internal/messaging"
+	"feed-api/internal/repository"
+	"testing"
+	"time"
+)
+
+// broadcastRange broadcasts the test messages numbered from up to, but not
+// including, to.
+func broadcastRange(t *testing.T, b *Broadcaster, from, to int) {
+	t.Helper()
+	for n := from; n < to; n++ {
+		b.Broadcast(messaging.NewEventMessage[messaging.Eventable](newTestMessage(t, n)))
+	}
+}
+
+// drain returns the ids of the buffered events without blocking, and whether
+// the events channel was closed.
+func drain(client *Client) (ids []string, closed bool) {
+	for {
+		select {
+		case event, ok := <-client.Events():
+			if !ok {
+				return ids, true
+			}
+			ids = append(ids, event.Data().(*repository.Message).ID())
+		default:
+			return ids, false
+		}
+	}
+}
+
+func hasGap(client *Client) bool {
+	select {
+	case <-client.Gaps():
+		return true
+	default:
+		return false
+	}
+}
+
+func newTestBroadcaster(t *testing.T, options ...BroadcasterOption) (*Broadcaster, *Client) {
+	t.Helper()
+	b, err := NewBroadcaster(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(nil)
+	b.Register(client)
+	return b, client
+}
+
+func TestBroadcasterDropOldest(t *testing.T) {
+	b, client := newTestBroadcaster(t)
+
+	broadcastRange(t, b, 0, clientBufferSize)
+	if client.Dropped() != 0 || hasGap(client) {
+		t.Fatalf("dropped %d events filling the buffer, want none", client.Dropped())
+	}
+
+	broadcastRange(t, b, clientBufferSize, clientBufferSize+3)
+	if client.Dropped() != 3 {
+		t.Fatalf("dropped %d events, want 3", client.Dropped())
+	}
+	if !hasGap(client) {
+		t.Fatal("no gap signal after dropping events")
+	}
+	if hasGap(client) {
+		t.Fatal("gap signalled once per drop, want it coalesced")
+	}
+
+	ids, closed := drain(client)
+	if closed || len(ids) != clientBufferSize {
+		t.Fatalf("got %d buffered events, closed %v, want a full buffer", len(ids), closed)
+	}
+	if want := newTestMessage(t, 3).ID(); ids[0] != want {
+		t.Fatalf("oldest buffered event is %s, want %s", ids[0], want)
+	}
+	if want := newTestMessage(t, clientBufferSize+2).ID(); ids[len(ids)-1] != want {
+		t.Fatalf("newest buffered event is %s, want %s", ids[len(ids)-1], want)
+	}
+}
+
+func TestBroadcasterDisconnectAfterDrops(t *testing.T) {
+	b, client := newTestBroadcaster(t, WithSlowConsumerPolicy(DisconnectAfterDrops, 2, 0))
+
+	broadcastRange(t, b, 0, clientBufferSize+1)
+	if client.Dropped() != 1 || !hasGap(client) {
+		t.Fatalf("dropped %d events, want 1 and a gap signal", client.Dropped())
+	}
+	if _, closed := drain(client); closed {
+		t.Fatal("client disconnected before reaching the drop limit")
+	}
+
+	broadcastRange(t, b, 0, clientBufferSize+2)
+	ids, closed := drain(client)
+	if !closed {
+		t.Fatal("client still connected after reaching the drop limit")
+	}
+	if client.Dropped() != 2 || len(ids) != clientBufferSize {
+		t.Fatalf("dropped %d and buffered %d events, want 2 and a full buffer", client.Dropped(), len(ids))
+	}
+
+	// Later broadcasts skip the disconnected client.
+	broadcastRange(t, b, 0, 1)
+}
+
+func TestBroadcasterBlockWithTimeout(t *testing.T) {
+	timeout := 100 * time.Millisecond
+	b, client := newTestBroadcaster(t, WithSlowConsumerPolicy(BlockWithTimeout, 0, timeout))
+	broadcastRange(t, b, 0, clientBufferSize)
+
+	// A client that makes room in time gets the event.
+	event := messaging.NewEventMessage[messaging.Eventable](newTestMessage(t, clientBufferSize))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Broadcast(event)
+	}()
+	time.Sleep(timeout / 4)
+	<-client.Events()
+	<-done
+	if client.Dropped() != 0 {
+		t.Fatalf("dropped %d events for a client that caught up, want none", client.Dropped())
+	}
+
+	// A client that does not is skipped after the timeout.
+	start := time.Now()
+	broadcastRange(t, b, clientBufferSize+1, clientBufferSize+2)
+	if waited := time.Since(start); waited < timeout {
+		t.Fatalf("broadcast returned after %v, want it to wait %v", waited, timeout)
+	}
+	if client.Dropped() != 1 || !hasGap(client) {
+		t.Fatalf("dropped %d events, want 1 and a gap signal", client.Dropped())
+	}
+	ids, _ := drain(client)
+	if want := newTestMessage(t, clientBufferSize).ID(); ids[len(ids)-1] != want {
+		t.Fatalf("newest buffered event is %s, want %s", ids[len(ids)-1], want)
+	}
+}
+
+func TestBroadcasterSkipsFilteredClients(t *testing.T) {
+	b, err := NewBroadcaster(WithSlowConsumerPolicy(DisconnectAfterDrops, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(AuthorFilter([]string{"someone-else"}))
+	b.Register(client)
+
+	broadcastRange(t, b, 0, clientBufferSize+5)
+
+	if ids, closed := drain(client); len(ids) != 0 || closed || client.Dropped() != 0 {
+		t.Fatalf("got %d events, closed %v, dropped %d, want nothing", len(ids), closed, client.Dropped())
+	}
+}
+
+func TestWithSlowConsumerPolicyValidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   SlowConsumerPolicy
+		maxDrops uint64
+		timeout  time.Duration
+		wantErr  bool
+	}{
+		{"drop oldest", DropOldest, 0, 0, false},
+		{"disconnect", DisconnectAfterDrops, 5, 0, false},
+		{"disconnect without limit", DisconnectAfterDrops, 0, 0, true},
+		{"block", BlockWithTimeout, 0, time.Second, false},
+		{"block without timeout", BlockWithTimeout, 0, 0, true},
+		{"unknown", "retry", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBroadcaster(WithSlowConsumerPolicy(tt.policy, tt.maxDrops, tt.timeout))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+const (
+	codeInvalidRequest       = "invalid_request"
+	codeValidationFailed     = "validation_failed"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codePayloadTooLarge      = "payload_too_large"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeConflict             = "conflict"
+	codeIdempotencyKeyReused = "idempotency_key_reused"
+	codeUnavailable          = "unavailable"
+	codeInternal             = "internal_error"
+)
+
+// apiError is the body of every error response:
+// {"error":{"code":...,"message":...,"field":...}}.
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func badRequest(field, message string) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: codeInvalidRequest, Field: field, Message: message}
+}
+
+func validationFailed(field, message string) *apiError {
+	return &apiError{Status: http.StatusUnprocessableEntity, Code: codeValidationFailed, Field: field, Message: message}
+}
+
+func unauthorized(message string) *apiError {
+	return &apiError{Status: http.StatusUnauthorized, Code: codeUnauthorized, Message: message}
+}
+
+func forbidden(message string) *apiError {
+	return &apiError{Status: http.StatusForbidden, Code: codeForbidden, Message: message}
+}
+
+func notFound(message string) *apiError {
+	return &apiError{Status: http.StatusNotFound, Code: codeNotFound, Message: message}
+}
+
+func conflict(message string) *apiError {
+	return &apiError{Status: http.StatusConflict, Code: codeConflict, Message: message}
+}
+
+func unavailable(message string) *apiError {
+	return &apiError{Status: http.StatusServiceUnavailable, Code: codeUnavailable, Message: message}
+}
+
+func internalError(message string) *apiError {
+	return &apiError{Status: http.StatusInternalServerError, Code: codeInternal, Message: message}
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
+
+func writeError(rw http.ResponseWriter, apiErr *apiError) {
+	type ErrorResponse struct {
+		Error *apiError `json:"error"`
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(apiErr.Status)
+	if err := json.NewEncoder(rw).Encode(ErrorResponse{Error: apiErr}); err != nil {
+		log.Println("Error encoding error response:", err)
+	}
+}
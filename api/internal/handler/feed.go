@@ -5,17 +5,51 @@ import (
 	"encoding/json"
 	"feed-api/internal/messaging"
 	"feed-api/internal/repository"
+	"feed-api/internal/tags"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	// feedHistoryLimit bounds how many recent messages are replayed to a new feed client.
+	// Older messages are available through GET /api/messages.
+	feedHistoryLimit = 100
+
+	// feedResumePageSize is the page size used when replaying missed messages
+	// for a client resuming with Last-Event-ID.
+	feedResumePageSize = 200
+
+	// feedResumeMaxPages bounds how far back a resuming client is replayed.
+	// A client further behind, or with a forged Last-Event-ID, is sent a reset
+	// frame and the recent window instead.
+	feedResumeMaxPages = 5
+
+	// feedSentLimit is how many of the messages most recently written to a
+	// client are remembered to drop duplicates. It covers a full replay, so
+	// nothing delivered both from history and live is sent twice.
+	feedSentLimit = 1000
+
+	timelineGlobal = "global"
+	timelineHome   = "home"
+
+	sseEventMessage        = "message"
+	sseEventMessageDeleted = "message.deleted"
+	sseEventMessageEdited  = "message.edited"
+	sseEventMessageStats   = "message.stats"
+	sseEventRepost         = "repost"
+	sseEventGap            = "gap"
+	sseEventReset          = "reset"
 )
 
 type FeedHandler struct {
-	broadcaster *Broadcaster
+	broadcaster ClientRegistry
 	repo        Repository
 }
 
-func NewFeedHandler(broadcaster *Broadcaster, repo Repository) *FeedHandler {
+func NewFeedHandler(broadcaster ClientRegistry, repo Repository) *FeedHandler {
 	return &FeedHandler{
 		broadcaster: broadcaster,
 		repo:        repo,
@@ -23,39 +57,89 @@ func NewFeedHandler(broadcaster *Broadcaster, repo Repository) *FeedHandler {
 }
 
 func (f *FeedHandler) GetFeed(rw http.ResponseWriter, r *http.Request) {
+	lastEventID, err := repository.DecodeCursor(r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		writeError(rw, badRequest("Last-Event-ID", "Invalid Last-Event-ID"))
+		return
+	}
+
+	var source *timeline
+	switch query := r.URL.Query(); query.Get("timeline") {
+	case "", timelineGlobal:
+		source = f.globalTimeline()
+		if query.Has("tag") {
+			tag, ok := tags.NormalizeHashtag(query.Get("tag"))
+			if !ok {
+				writeError(rw, badRequest("tag", "Invalid hashtag"))
+				return
+			}
+			source = f.tagTimeline(tag)
+		}
+	case timelineHome:
+		if query.Has("tag") {
+			writeError(rw, badRequest("tag", "Tag feeds cannot be combined with the home timeline"))
+			return
+		}
+		userID := principalID(r)
+		if userID == "" {
+			writeError(rw, unauthorized("Authentication required for home timeline"))
+			return
+		}
+		source, err = f.homeTimeline(r.Context(), userID)
+		if err != nil {
+			log.Println("Error loading home timeline:", err)
+			writeError(rw, internalError("Failed to load home timeline"))
+			return
+		}
+	default:
+		writeError(rw, badRequest("timeline", "Unknown timeline"))
+		return
+	}
+
 	rw.Header().Set("Content-Type", "text/event-stream")
 	rw.Header().Set("Cache-Control", "no-cache")
 	rw.Header().Set("Connection", "keep-alive")
 
 	flusher, ok := rw.(http.Flusher)
 	if !ok {
-		http.Error(rw, "Streaming unsupported!", http.StatusInternalServerError)
+		writeError(rw, internalError("Streaming unsupported"))
 		return
 	}
 
-	clientChan := make(chan *messaging.Event[*repository.Message], 10)
-	f.broadcaster.Register(clientChan)
-	defer f.broadcaster.Unregister(clientChan)
+	// Register before reading history so nothing committed in between is missed.
+	// Anything delivered by both paths is only sent once.
+	client := NewClient(source.filter)
+	f.broadcaster.Register(client)
+	defer f.broadcaster.Unregister(client)
 
-	historicalMessages, err := f.repo.GetAllMessages(r.Context())
-	if err != nil {
-		log.Println("Error fetching historical messages:", err)
+	sent := newSentMessages(feedSentLimit, lastEventID)
+	if lastEventID.IsZero() {
+		err = f.replayRecent(r.Context(), rw, source, sent)
+	} else {
+		err = f.replayAfter(r.Context(), rw, source, sent)
 	}
-	for _, msg := range historicalMessages {
-		err = f.writeSseEvent(rw, msg)
-		if err != nil {
-			log.Println("Error writing sse-event:", err)
-		}
+	if err != nil {
+		log.Println("Error replaying historical messages:", err)
 	}
 	flusher.Flush()
 
 	for {
 		select {
-		case event := <-clientChan:
-			err = event.Process(r.Context(), func(ctx context.Context, msg *repository.Message) error {
-				return f.writeSseEvent(rw, msg)
-			})
-			if err != nil {
+		case event, ok := <-client.Events():
+			if !ok {
+				// Disconnected by the broadcaster as a slow consumer.
+				if err = f.writeGap(rw, sent, client); err != nil {
+					log.Println("Error writing sse event:", err)
+				}
+				flusher.Flush()
+				return
+			}
+			if err = f.writeEvent(rw, source, sent, event); err != nil {
+				log.Println("Error writing sse event:", err)
+			}
+			flusher.Flush()
+		case <-client.Gaps():
+			if err = f.writeGap(rw, sent, client); err != nil {
 				log.Println("Error writing sse event:", err)
 			}
 			flusher.Flush()
@@ -65,15 +149,386 @@ func (f *FeedHandler) GetFeed(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (f *FeedHandler) writeSseEvent(rw http.ResponseWriter, data any) error {
+type pageFunc func(ctx context.Context, cursor repository.Cursor, limit int) ([]*repository.Message, error)
+
+type repostPageFunc func(ctx context.Context, cursor repository.Cursor, limit int) ([]*repository.Repost, error)
+
+// timeline is where a feed connection reads its history from and which live
+// events it receives. Timelines without repost pages replay messages only.
+type timeline struct {
+	before        pageFunc
+	after         pageFunc
+	repostsBefore repostPageFunc
+	repostsAfter  repostPageFunc
+	filter        MessageFilter
+	// sentChangesOnly drops changes to and stats of messages that were not
+	// sent on this connection.
+	sentChangesOnly bool
+}
+
+func (f *FeedHandler) globalTimeline() *timeline {
+	return &timeline{
+		before:        f.repo.GetMessagesBefore,
+		after:         f.repo.GetMessagesAfter,
+		repostsBefore: f.repo.GetRepostsBefore,
+		repostsAfter:  f.repo.GetRepostsAfter,
+	}
+}
+
+// homeTimeline only carries messages from users userID follows. The follow
+// set is read once per connection; follows made later apply on reconnect.
+func (f *FeedHandler) homeTimeline(ctx context.Context, userID string) (*timeline, error) {
+	followees, err := f.repo.GetFollowees(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &timeline{
+		before: func(ctx context.Context, cursor repository.Cursor, limit int) ([]*repository.Message, error) {
+			return f.repo.GetHomeTimelineBefore(ctx, userID, cursor, limit)
+		},
+		after: func(ctx context.Context, cursor repository.Cursor, limit int) ([]*repository.Message, error) {
+			return f.repo.GetHomeTimelineAfter(ctx, userID, cursor, limit)
+		},
+		repostsBefore: func(ctx context.Context, cursor repository.Cursor, limit int) ([]*repository.Repost, error) {
+			return f.repo.GetHomeRepostsBefore(ctx, userID, cursor, limit)
+		},
+		repostsAfter: func(ctx context.Context, cursor repository.Cursor, limit int) ([]*repository.Repost, error) {
+			return f.repo.GetHomeRepostsAfter(ctx, userID, cursor, limit)
+		},
+		filter: AuthorFilter(followees),
+	}, nil
+}
+
+// tagTimeline only carries messages with the given hashtag. Changes and stats
+// are sent for the messages the client was sent, also when an edit removes the
+// tag, as long as the message is among the last feedSentLimit sent.
+func (f *FeedHandler) tagTimeline(tag string) *timeline {
+	return &timeline{
+		before: func(ctx context.Context, cursor repository.Cursor, limit int) ([]*repository.Message, error) {
+			return f.repo.GetTaggedMessagesBefore(ctx, tag, cursor, limit)
+		},
+		after: func(ctx context.Context, cursor repository.Cursor, limit int) ([]*repository.Message, error) {
+			return f.repo.GetTaggedMessagesAfter(ctx, tag, cursor, limit)
+		},
+		filter:          TagFilter(tag),
+		sentChangesOnly: true,
+	}
+}
+
+// feedItem is a message or a repost in a timeline's history.
+type feedItem struct {
+	message *repository.Message
+	repost  *repository.Repost
+}
+
+func (i feedItem) cursor() repository.Cursor {
+	if i.repost != nil {
+		return i.repost.Cursor()
+	}
+	return i.message.Cursor()
+}
+
+// itemsBefore returns up to limit messages and reposts strictly older than
+// cursor, newest first.
+func (t *timeline) itemsBefore(ctx context.Context, cursor repository.Cursor, limit int) ([]feedItem, error) {
+	messages, err := t.before(ctx, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	var reposts []*repository.Repost
+	if t.repostsBefore != nil {
+		if reposts, err = t.repostsBefore(ctx, cursor, limit); err != nil {
+			return nil, err
+		}
+	}
+	return mergeItems(messages, reposts, limit, -1), nil
+}
+
+// itemsAfter returns up to limit messages and reposts strictly newer than
+// cursor, oldest first.
+func (t *timeline) itemsAfter(ctx context.Context, cursor repository.Cursor, limit int) ([]feedItem, error) {
+	messages, err := t.after(ctx, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	var reposts []*repository.Repost
+	if t.repostsAfter != nil {
+		if reposts, err = t.repostsAfter(ctx, cursor, limit); err != nil {
+			return nil, err
+		}
+	}
+	return mergeItems(messages, reposts, limit, 1), nil
+}
+
+// mergeItems orders two pages read with the same cursor and limit by cursor,
+// ascending for direction 1 and descending for -1, and keeps the first limit.
+func mergeItems(messages []*repository.Message, reposts []*repository.Repost, limit, direction int) []feedItem {
+	items := make([]feedItem, 0, len(messages)+len(reposts))
+	for _, msg := range messages {
+		items = append(items, feedItem{message: msg})
+	}
+	for _, repost := range reposts {
+		items = append(items, feedItem{repost: repost})
+	}
+	slices.SortFunc(items, func(a, b feedItem) int {
+		return direction * a.cursor().Compare(b.cursor())
+	})
+	return items[:min(limit, len(items))]
+}
+
+// replayRecent sends the most recent window of messages and reposts, oldest
+// first.
+func (f *FeedHandler) replayRecent(ctx context.Context, rw http.ResponseWriter, source *timeline, sent *sentMessages) error {
+	items, err := source.itemsBefore(ctx, repository.Cursor{}, feedHistoryLimit)
+	if err != nil {
+		return err
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		if err = f.writeItem(rw, sent, items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayAfter sends every message and repost newer than sent.last, which
+// holds the cursor the client last saw. If there are more than
+// feedResumeMaxPages pages of them, it sends a reset frame followed by the
+// recent window instead.
+func (f *FeedHandler) replayAfter(ctx context.Context, rw http.ResponseWriter, source *timeline, sent *sentMessages) error {
+	var missed []feedItem
+	cursor := sent.last
+	for range feedResumeMaxPages {
+		items, err := source.itemsAfter(ctx, cursor, feedResumePageSize)
+		if err != nil {
+			return err
+		}
+		missed = append(missed, items...)
+		if len(items) < feedResumePageSize {
+			for _, item := range missed {
+				if err = f.writeItem(rw, sent, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		cursor = items[len(items)-1].cursor()
+	}
+
+	if err := f.writeReset(rw, sent); err != nil {
+		return err
+	}
+	sent.reset()
+	return f.replayRecent(ctx, rw, source, sent)
+}
+
+func (f *FeedHandler) writeItem(rw http.ResponseWriter, sent *sentMessages, item feedItem) error {
+	if item.repost != nil {
+		return f.writeRepost(rw, sent, item.repost)
+	}
+	return f.writeMessage(rw, sent, item.message)
+}
+
+// writeMessage sends msg unless it has already been sent to the client. Live
+// messages are not ordered by time across partitions, so a message older than
+// the last one sent is still sent.
+func (f *FeedHandler) writeMessage(rw http.ResponseWriter, sent *sentMessages, msg *repository.Message) error {
+	if !sent.add(msg.Cursor()) {
+		return nil
+	}
+	return f.writeSseEvent(rw, sseEventMessage, msg.Cursor().Encode(), msg)
+}
+
+// writeEvent sends a live event: a new message or repost, or a change to a
+// message the client may have been sent before.
+func (f *FeedHandler) writeEvent(rw http.ResponseWriter, source *timeline, sent *sentMessages, event *messaging.Event[messaging.Eventable]) error {
+	switch data := event.Data().(type) {
+	case *repository.Message:
+		return f.writeMessage(rw, sent, data)
+	case *repository.MessageChange:
+		if source.sentChangesOnly && !sent.has(data.MessageID()) {
+			return nil
+		}
+		return f.writeChange(rw, data)
+	case *repository.MessageStats:
+		if source.sentChangesOnly && !sent.has(data.MessageID()) {
+			return nil
+		}
+		return f.writeStats(rw, data)
+	case *repository.Repost:
+		return f.writeRepost(rw, sent, data)
+	default:
+		return nil
+	}
+}
+
+// writeChange tells the client to update or remove a message in place.
+// Changes carry no event id: they do not move the client's position in the
+// feed.
+func (f *FeedHandler) writeChange(rw http.ResponseWriter, change *repository.MessageChange) error {
+	type ChangeEvent struct {
+		ID        string    `json:"id"`
+		UserID    string    `json:"user_id"`
+		Content   string    `json:"content,omitempty"`
+		EditedAt  time.Time `json:"edited_at,omitzero"`
+		DeletedAt time.Time `json:"deleted_at,omitzero"`
+	}
+	event := ChangeEvent{
+		ID:     change.MessageID(),
+		UserID: change.UserID(),
+	}
+	switch change.Kind() {
+	case repository.ChangeDeleted:
+		event.DeletedAt = change.ChangedAt()
+		return f.writeSseEvent(rw, sseEventMessageDeleted, "", event)
+	case repository.ChangeEdited:
+		event.Content = change.Content()
+		event.EditedAt = change.ChangedAt()
+		return f.writeSseEvent(rw, sseEventMessageEdited, "", event)
+	default:
+		return nil
+	}
+}
+
+// writeStats sends the new counters of a message. Like changes, stats carry
+// no event id.
+func (f *FeedHandler) writeStats(rw http.ResponseWriter, stats *repository.MessageStats) error {
+	type StatsEvent struct {
+		ID          string `json:"id"`
+		LikeCount   int    `json:"like_count"`
+		RepostCount int    `json:"repost_count"`
+		ReplyCount  int    `json:"reply_count"`
+	}
+	event := StatsEvent{
+		ID:          stats.MessageID(),
+		LikeCount:   stats.LikeCount(),
+		RepostCount: stats.RepostCount(),
+		ReplyCount:  stats.ReplyCount(),
+	}
+	return f.writeSseEvent(rw, sseEventMessageStats, "", event)
+}
+
+// writeRepost sends a repost with the original message unless it has already
+// been sent. Reposts are positioned in the feed like messages, so a client
+// can resume from one.
+func (f *FeedHandler) writeRepost(rw http.ResponseWriter, sent *sentMessages, repost *repository.Repost) error {
+	if !sent.add(repost.Cursor()) {
+		return nil
+	}
+	type RepostEvent struct {
+		ID        string              `json:"id"`
+		UserID    string              `json:"user_id"`
+		CreatedAt time.Time           `json:"created_at"`
+		Message   *repository.Message `json:"message"`
+	}
+	event := RepostEvent{
+		ID:        repost.ID(),
+		UserID:    repost.UserID(),
+		CreatedAt: repost.CreatedAt(),
+		Message:   repost.Message(),
+	}
+	return f.writeSseEvent(rw, sseEventRepost, repost.Cursor().Encode(), event)
+}
+
+// writeGap tells the client that live events were dropped after last_event_id
+// and it should re-fetch history from that point.
+func (f *FeedHandler) writeGap(rw http.ResponseWriter, sent *sentMessages, client *Client) error {
+	type GapEvent struct {
+		LastEventID string `json:"last_event_id,omitempty"`
+		Dropped     uint64 `json:"dropped"`
+	}
+	gap := GapEvent{
+		LastEventID: sent.last.Encode(),
+		Dropped:     client.Dropped(),
+	}
+	return f.writeSseEvent(rw, sseEventGap, "", gap)
+}
+
+// writeReset tells the client that it is too far behind to be resumed from
+// last_event_id. It should discard the messages it shows; the recent window
+// follows as for a new connection.
+func (f *FeedHandler) writeReset(rw http.ResponseWriter, sent *sentMessages) error {
+	type ResetEvent struct {
+		LastEventID string `json:"last_event_id"`
+		Reason      string `json:"reason"`
+	}
+	reset := ResetEvent{
+		LastEventID: sent.last.Encode(),
+		Reason:      "too_far_behind",
+	}
+	return f.writeSseEvent(rw, sseEventReset, "", reset)
+}
+
+func (f *FeedHandler) writeSseEvent(rw http.ResponseWriter, eventType, id string, data any) error {
 	dataBytes, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
-	_, err = fmt.Fprintf(rw, "data: %s\n\n", dataBytes)
+	if id != "" {
+		if _, err = fmt.Fprintf(rw, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", eventType, dataBytes)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// sentMessages remembers the ids of the last messages and reposts written to
+// a feed client, so anything delivered both from history and live, or
+// delivered live twice, is only sent once.
+type sentMessages struct {
+	// last is the position of the message written last, which the client
+	// resumes from.
+	last  repository.Cursor
+	ids   map[string]struct{}
+	order []string
+	next  int
+	limit int
+}
+
+// newSentMessages starts from the message the client last saw, if any.
+func newSentMessages(limit int, last repository.Cursor) *sentMessages {
+	s := &sentMessages{
+		ids:   make(map[string]struct{}, limit),
+		order: make([]string, 0, limit),
+		limit: limit,
+	}
+	if !last.IsZero() {
+		s.add(last)
+	}
+	return s
+}
+
+// add records the message at c and reports false if it was sent before.
+func (s *sentMessages) add(c repository.Cursor) bool {
+	if _, ok := s.ids[c.ID]; ok {
+		return false
+	}
+	if len(s.order) < s.limit {
+		s.order = append(s.order, c.ID)
+	} else {
+		delete(s.ids, s.order[s.next])
+		s.order[s.next] = c.ID
+		s.next = (s.next + 1) % s.limit
+	}
+	s.ids[c.ID] = struct{}{}
+	s.last = c
+	return true
+}
+
+func (s *sentMessages) has(id string) bool {
+	_, ok := s.ids[id]
+	return ok
+}
+
+// reset forgets every message, after the client was told to start over.
+func (s *sentMessages) reset() {
+	clear(s.ids)
+	s.order = s.order[:0]
+	s.next = 0
+	s.last = repository.Cursor{}
+}
@@ -0,0 +1,615 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"feed-api/internal/auth"
+	"feed-api/internal/messaging"
+	"feed-api/internal/repository"
+	"feed-api/internal/search"
+	"feed-api/internal/tags"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeRepository struct {
+	before  []*repository.Message // newest first
+	after   []*repository.Message
+	reposts []*repository.Repost // oldest first
+	onQuery func()
+	// statuses are reported by GetMessageStatus, by message id.
+	statuses map[string]fakeStatus
+	// keys are the reserved idempotency keys, by user id and key.
+	keys map[[2]string]fakeKey
+	// follows are the followees of each user, by follower id.
+	follows map[string]map[string]bool
+}
+
+type fakeKey struct {
+	message   *repository.Message
+	expiresAt time.Time
+}
+
+type fakeStatus struct {
+	message *repository.Message
+	status  repository.DeliveryStatus
+}
+
+func (r *fakeRepository) SaveMessage(ctx context.Context, msg *repository.Message) error {
+	return nil
+}
+
+func (r *fakeRepository) GetMessagesBefore(ctx context.Context, before repository.Cursor, limit int) ([]*repository.Message, error) {
+	if r.onQuery != nil {
+		r.onQuery()
+	}
+	var messages []*repository.Message
+	for _, msg := range r.before {
+		if (before.IsZero() || msg.Cursor().Compare(before) < 0) && len(messages) < limit {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+func (r *fakeRepository) GetMessagesAfter(ctx context.Context, after repository.Cursor, limit int) ([]*repository.Message, error) {
+	if r.onQuery != nil {
+		r.onQuery()
+	}
+	var messages []*repository.Message
+	for _, msg := range r.after {
+		if msg.Cursor().After(after) && len(messages) < limit {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+func (r *fakeRepository) GetHomeTimelineBefore(ctx context.Context, followerID string, before repository.Cursor, limit int) ([]*repository.Message, error) {
+	messages, err := r.GetMessagesBefore(ctx, before, limit)
+	return slices.DeleteFunc(messages, func(msg *repository.Message) bool {
+		return !r.follows[followerID][msg.UserID()]
+	}), err
+}
+
+func (r *fakeRepository) GetHomeTimelineAfter(ctx context.Context, followerID string, after repository.Cursor, limit int) ([]*repository.Message, error) {
+	return r.GetMessagesAfter(ctx, after, limit)
+}
+
+func (r *fakeRepository) GetTaggedMessagesBefore(ctx context.Context, tag string, before repository.Cursor, limit int) ([]*repository.Message, error) {
+	return r.GetMessagesBefore(ctx, before, limit)
+}
+
+func (r *fakeRepository) GetTaggedMessagesAfter(ctx context.Context, tag string, after repository.Cursor, limit int) ([]*repository.Message, error) {
+	return r.GetMessagesAfter(ctx, after, limit)
+}
+
+func (r *fakeRepository) GetMentionsBefore(ctx context.Context, userID string, before repository.Cursor, limit int) ([]*repository.Message, error) {
+	var messages []*repository.Message
+	for _, msg := range r.before {
+		mentioned := slices.Contains(tags.Extract(msg.Content()).Mentions, userID)
+		if mentioned && (before.IsZero() || msg.Cursor().Compare(before) < 0) && len(messages) < limit {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+func (r *fakeRepository) GetRepostsBefore(ctx context.Context, before repository.Cursor, limit int) ([]*repository.Repost, error) {
+	var reposts []*repository.Repost
+	for i := len(r.reposts) - 1; i >= 0 && len(reposts) < limit; i-- {
+		if before.IsZero() || before.After(r.reposts[i].Cursor()) {
+			reposts = append(reposts, r.reposts[i])
+		}
+	}
+	return reposts, nil
+}
+
+func (r *fakeRepository) GetRepostsAfter(ctx context.Context, after repository.Cursor, limit int) ([]*repository.Repost, error) {
+	var reposts []*repository.Repost
+	for _, repost := range r.reposts {
+		if repost.Cursor().After(after) && len(reposts) < limit {
+			reposts = append(reposts, repost)
+		}
+	}
+	return reposts, nil
+}
+
+func (r *fakeRepository) GetHomeRepostsBefore(ctx context.Context, followerID string, before repository.Cursor, limit int) ([]*repository.Repost, error) {
+	return r.GetRepostsBefore(ctx, before, limit)
+}
+
+func (r *fakeRepository) GetHomeRepostsAfter(ctx context.Context, followerID string, after repository.Cursor, limit int) ([]*repository.Repost, error) {
+	return r.GetRepostsAfter(ctx, after, limit)
+}
+
+func (r *fakeRepository) SearchMessages(ctx context.Context, query search.Query, before repository.Cursor, limit int) ([]*repository.Message, error) {
+	return nil, nil
+}
+
+func (r *fakeRepository) Follow(ctx context.Context, followerID, followeeID string) error {
+	if r.follows == nil {
+		r.follows = make(map[string]map[string]bool)
+	}
+	if r.follows[followerID] == nil {
+		r.follows[followerID] = make(map[string]bool)
+	}
+	r.follows[followerID][followeeID] = true
+	return nil
+}
+
+func (r *fakeRepository) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	delete(r.follows[followerID], followeeID)
+	return nil
+}
+
+func (r *fakeRepository) Like(ctx context.Context, messageID, userID string) error {
+	return nil
+}
+
+func (r *fakeRepository) Unlike(ctx context.Context, messageID, userID string) error {
+	return nil
+}
+
+func (r *fakeRepository) Repost(ctx context.Context, messageID, userID string) error {
+	return nil
+}
+
+func (r *fakeRepository) Unrepost(ctx context.Context, messageID, userID string) error {
+	return nil
+}
+
+func (r *fakeRepository) GetFollowees(ctx context.Context, followerID string) ([]string, error) {
+	return slices.Sorted(maps.Keys(r.follows[followerID])), nil
+}
+
+func (r *fakeRepository) ReserveIdempotencyKey(ctx context.Context, key string, msg *repository.Message, ttl time.Duration) (*repository.Message, bool, error) {
+	id := [2]string{msg.UserID(), key}
+	if stored, ok := r.keys[id]; ok && time.Now().Before(stored.expiresAt) {
+		return stored.message, false, nil
+	}
+	if r.keys == nil {
+		r.keys = make(map[[2]string]fakeKey)
+	}
+	r.keys[id] = fakeKey{message: msg, expiresAt: time.Now().Add(ttl)}
+	return msg, true, nil
+}
+
+func (r *fakeRepository) MarkQueued(ctx context.Context, msg *repository.Message) error {
+	return nil
+}
+
+func (r *fakeRepository) MarkFailed(ctx context.Context, msg *repository.Message, reason string) error {
+	return nil
+}
+
+func (r *fakeRepository) GetMessageStatus(ctx context.Context, id string) (*repository.Message, repository.DeliveryStatus, error) {
+	stored, ok := r.statuses[id]
+	if !ok {
+		return nil, "", repository.ErrNotFound
+	}
+	return stored.message, stored.status, nil
+}
+
+func (r *fakeRepository) GetThread(ctx context.Context, id string, after repository.Cursor, limit int) ([]*repository.Message, error) {
+	return nil, repository.ErrNotFound
+}
+
+type fakeBroadcaster struct {
+	mu     sync.Mutex
+	client *Client
+}
+
+func (b *fakeBroadcaster) Register(client *Client) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.client = client
+}
+
+func (b *fakeBroadcaster) Unregister(client *Client) {}
+
+func (b *fakeBroadcaster) Broadcast(msg messaging.Eventable) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if event := messaging.NewEventMessage[messaging.Eventable](msg); b.client.accepts(event) {
+		b.client.events <- event
+	}
+}
+
+type streamRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+	body   bytes.Buffer
+}
+
+func newStreamRecorder() *streamRecorder {
+	return &streamRecorder{header: make(http.Header)}
+}
+
+func (s *streamRecorder) Header() http.Header { return s.header }
+
+func (s *streamRecorder) WriteHeader(statusCode int) {}
+
+func (s *streamRecorder) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.body.Write(p)
+}
+
+func (s *streamRecorder) Flush() {}
+
+func (s *streamRecorder) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.body.String()
+}
+
+func newTestMessage(t *testing.T, n int) *repository.Message {
+	t.Helper()
+	return newTestMessageBy(t, n, "user-1")
+}
+
+// newTestMessageBy is newTestMessage written by userID.
+func newTestMessageBy(t *testing.T, n int, userID string) *repository.Message {
+	t.Helper()
+	payload := fmt.Sprintf(
+		`{"id":"00000000-0000-0000-0000-%012d","user_id":%q,"content":"message %d","created_at":"%s"}`,
+		n, userID, n, time.Date(2025, 1, 1, 0, 0, n, 0, time.UTC).Format(time.RFC3339Nano),
+	)
+	var msg repository.Message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		t.Fatal(err)
+	}
+	return &msg
+}
+
+// newTestRepost is a repost of msg made n seconds into 2025.
+func newTestRepost(t *testing.T, n int, msg *repository.Message) *repository.Repost {
+	t.Helper()
+	original, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := fmt.Sprintf(
+		`{"id":"00000000-0000-0000-0001-%012d","user_id":"user-2","created_at":"%s","message":%s}`,
+		n, time.Date(2025, 1, 1, 0, 0, n, 0, time.UTC).Format(time.RFC3339Nano), original,
+	)
+	var repost repository.Repost
+	if err := json.Unmarshal([]byte(payload), &repost); err != nil {
+		t.Fatal(err)
+	}
+	return &repost
+}
+
+// runFeed serves the feed until want messages and reposts have been written
+// and returns the ids of the streamed events in order.
+func runFeed(t *testing.T, handler *FeedHandler, lastEventID string, want int) []string {
+	t.Helper()
+	return runFeedRequest(t, handler, "/api/feed", lastEventID, want)
+}
+
+// runFeedRequest is runFeed for a feed URL with query parameters.
+func runFeedRequest(t *testing.T, handler *FeedHandler, target, lastEventID string, want int) []string {
+	t.Helper()
+	return runFeedAs(t, handler, target, "", lastEventID, want)
+}
+
+// runFeedAs is runFeedRequest as userID, or unauthenticated if userID is
+// empty.
+func runFeedAs(t *testing.T, handler *FeedHandler, target, userID, lastEventID string, want int) []string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	if userID != "" {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: userID}))
+	}
+	rec := newStreamRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.GetFeed(rec, req)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for countFeedEvents(rec.String()) < want {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for %d messages, got:\n%s", want, rec.String())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	// Give the handler a chance to write anything it should not have.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+
+	var ids []string
+	for _, line := range strings.Split(rec.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var payload struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, payload.ID)
+	}
+	return ids
+}
+
+// countFeedEvents counts messages, changes, stats and reposts, but not gap
+// or reset frames.
+func countFeedEvents(body string) int {
+	return strings.Count(body, "event: message") + strings.Count(body, "event: repost")
+}
+
+func assertIDs(t *testing.T, got []string, want ...*repository.Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages %v, want %d", len(got), got, len(want))
+	}
+	for i, msg := range want {
+		if got[i] != msg.ID() {
+			t.Fatalf("message %d: got %s, want %s", i, got[i], msg.ID())
+		}
+	}
+}
+
+func TestGetFeedDropsLiveEventsAlreadyReplayed(t *testing.T) {
+	m1, m2, m3 := newTestMessage(t, 1), newTestMessage(t, 2), newTestMessage(t, 3)
+	broadcaster := &fakeBroadcaster{}
+	repo := &fakeRepository{before: []*repository.Message{m2, m1}}
+	// m2 is committed after the client registered but before history is read,
+	// so it reaches the client both from history and live.
+	repo.onQuery = func() {
+		broadcaster.Broadcast(m2)
+		broadcaster.Broadcast(m3)
+	}
+
+	ids := runFeed(t, NewFeedHandler(broadcaster, repo), "", 3)
+
+	assertIDs(t, ids, m1, m2, m3)
+}
+
+func TestGetFeedSendsLateMessagesOlderThanHistory(t *testing.T) {
+	m1, m2, m3 := newTestMessage(t, 1), newTestMessage(t, 2), newTestMessage(t, 3)
+	broadcaster := &fakeBroadcaster{}
+	repo := &fakeRepository{before: []*repository.Message{m3, m2}}
+	// m1 was created first but committed after history was read.
+	repo.onQuery = func() {
+		broadcaster.Broadcast(m1)
+		broadcaster.Broadcast(m3)
+	}
+
+	ids := runFeed(t, NewFeedHandler(broadcaster, repo), "", 3)
+
+	assertIDs(t, ids, m2, m3, m1)
+}
+
+func TestGetFeedSendsLiveMessagesOutOfTimestampOrder(t *testing.T) {
+	m1, m2, m3, m4 := newTestMessage(t, 1), newTestMessage(t, 2), newTestMessage(t, 3), newTestMessage(t, 4)
+	broadcaster := &fakeBroadcaster{}
+	repo := &fakeRepository{before: []*repository.Message{m1}}
+	// Partitions are processed concurrently, so m4 can be broadcast before
+	// m2 and m3; m4 is also delivered twice.
+	repo.onQuery = func() {
+		broadcaster.Broadcast(m4)
+		broadcaster.Broadcast(m2)
+		broadcaster.Broadcast(m4)
+		broadcaster.Broadcast(m3)
+	}
+
+	ids := runFeed(t, NewFeedHandler(broadcaster, repo), "", 4)
+
+	assertIDs(t, ids, m1, m4, m2, m3)
+}
+
+func TestGetFeedResumesFromLastEventID(t *testing.T) {
+	m1, m2, m3, m4 := newTestMessage(t, 1), newTestMessage(t, 2), newTestMessage(t, 3), newTestMessage(t, 4)
+	broadcaster := &fakeBroadcaster{}
+	repo := &fakeRepository{after: []*repository.Message{m1, m2, m3}}
+	repo.onQuery = func() {
+		broadcaster.Broadcast(m1)
+		broadcaster.Broadcast(m3)
+		broadcaster.Broadcast(m4)
+	}
+
+	ids := runFeed(t, NewFeedHandler(broadcaster, repo), m1.Cursor().Encode(), 3)
+
+	assertIDs(t, ids, m2, m3, m4)
+}
+
+func TestGetFeedResetsClientsTooFarBehind(t *testing.T) {
+	first := newTestMessage(t, 0)
+	repo := &fakeRepository{}
+	for n := 1; n <= feedResumeMaxPages*feedResumePageSize+1; n++ {
+		repo.after = append(repo.after, newTestMessage(t, n))
+	}
+	recent := repo.after[len(repo.after)-2:]
+	repo.before = []*repository.Message{recent[1], recent[0]}
+
+	ids := runFeed(t, NewFeedHandler(&fakeBroadcaster{}, repo), first.Cursor().Encode(), 2)
+
+	assertIDs(t, ids[1:], recent...)
+	if ids[0] != "" {
+		t.Fatalf("got %s before the recent window, want a reset frame", ids[0])
+	}
+}
+
+func TestGetFeedStreamsChangesToEarlierMessages(t *testing.T) {
+	m1, m2 := newTestMessage(t, 1), newTestMessage(t, 2)
+	broadcaster := &fakeBroadcaster{}
+	repo := &fakeRepository{before: []*repository.Message{m2, m1}}
+	// The deletion is of a message sent before but must still be sent.
+	repo.onQuery = func() {
+		broadcaster.Broadcast(repository.NewMessageDeletion(m1.ID(), m1.UserID()))
+	}
+
+	ids := runFeed(t, NewFeedHandler(broadcaster, repo), "", 3)
+
+	assertIDs(t, ids, m1, m2, m1)
+}
+
+func TestGetFeedStreamsStatsOfEarlierMessages(t *testing.T) {
+	m1, m2 := newTestMessage(t, 1), newTestMessage(t, 2)
+	var stats repository.MessageStats
+	payload := fmt.Sprintf(`{"id":"stats-1","message_id":%q,"user_id":%q,"like_count":3}`, m1.ID(), m1.UserID())
+	if err := json.Unmarshal([]byte(payload), &stats); err != nil {
+		t.Fatal(err)
+	}
+	broadcaster := &fakeBroadcaster{}
+	repo := &fakeRepository{before: []*repository.Message{m2, m1}}
+	repo.onQuery = func() {
+		broadcaster.Broadcast(&stats)
+	}
+
+	ids := runFeed(t, NewFeedHandler(broadcaster, repo), "", 3)
+
+	assertIDs(t, ids, m1, m2, m1)
+}
+
+func TestTagFilterMatchesHashtagsInContent(t *testing.T) {
+	var tagged, untagged repository.Message
+	if err := json.Unmarshal([]byte(`{"id":"1","user_id":"user-1","content":"Learning #Go today"}`), &tagged); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{"id":"2","user_id":"user-1","content":"Learning #golang today"}`), &untagged); err != nil {
+		t.Fatal(err)
+	}
+	var repost repository.Repost
+	if err := json.Unmarshal([]byte(`{"id":"3","user_id":"user-2","message":{"id":"1","content":"Learning #Go today"}}`), &repost); err != nil {
+		t.Fatal(err)
+	}
+	filter := TagFilter("go")
+
+	tests := []struct {
+		name string
+		data messaging.Eventable
+		want bool
+	}{
+		{name: "tagged message", data: &tagged, want: true},
+		{name: "other tag", data: &untagged, want: false},
+		{name: "edit removing the tag", data: repository.NewMessageEdit("1", "user-1", "no tag"), want: true},
+		{name: "deletion", data: repository.NewMessageDeletion("1", "user-1"), want: true},
+		{name: "repost", data: &repost, want: false},
+	}
+	for _, tt := range tests {
+		if got := filter(tt.data); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetFeedWithTagOnlySendsChangesToSentMessages(t *testing.T) {
+	m1, m2 := newTestMessage(t, 1), newTestMessage(t, 2)
+	broadcaster := &fakeBroadcaster{}
+	repo := &fakeRepository{before: []*repository.Message{m1}}
+	// m2 was never sent to the client: its deletion is dropped.
+	repo.onQuery = func() {
+		broadcaster.Broadcast(repository.NewMessageDeletion(m2.ID(), m2.UserID()))
+		broadcaster.Broadcast(repository.NewMessageEdit(m1.ID(), m1.UserID(), "tag removed"))
+		broadcaster.Broadcast(repository.NewMessageDeletion(m1.ID(), m1.UserID()))
+	}
+
+	ids := runFeedRequest(t, NewFeedHandler(broadcaster, repo), "/api/feed?tag=go", "", 3)
+
+	assertIDs(t, ids, m1, m1, m1)
+}
+
+func TestGetFeedReplaysRepostsWithMessages(t *testing.T) {
+	m1, m3 := newTestMessage(t, 1), newTestMessage(t, 3)
+	r2 := newTestRepost(t, 2, m1)
+	repo := &fakeRepository{
+		before:  []*repository.Message{m3, m1},
+		reposts: []*repository.Repost{r2},
+	}
+
+	ids := runFeed(t, NewFeedHandler(&fakeBroadcaster{}, repo), "", 3)
+
+	want := []string{m1.ID(), r2.ID(), m3.ID()}
+	if !slices.Equal(ids, want) {
+		t.Fatalf("got %v, want %v", ids, want)
+	}
+}
+
+func TestGetFeedResumesAcrossReposts(t *testing.T) {
+	m1, m3 := newTestMessage(t, 1), newTestMessage(t, 3)
+	r2 := newTestRepost(t, 2, m1)
+	repo := &fakeRepository{
+		after:   []*repository.Message{m1, m3},
+		reposts: []*repository.Repost{r2},
+	}
+	handler := NewFeedHandler(&fakeBroadcaster{}, repo)
+
+	ids := runFeed(t, handler, m1.Cursor().Encode(), 2)
+	if want := []string{r2.ID(), m3.ID()}; !slices.Equal(ids, want) {
+		t.Fatalf("resuming after a message: got %v, want %v", ids, want)
+	}
+
+	ids = runFeed(t, handler, r2.Cursor().Encode(), 1)
+	assertIDs(t, ids, m3)
+}
+
+func TestGetFeedDropsLiveRepostsAlreadyReplayed(t *testing.T) {
+	m1 := newTestMessage(t, 1)
+	r2 := newTestRepost(t, 2, m1)
+	broadcaster := &fakeBroadcaster{}
+	repo := &fakeRepository{
+		before:  []*repository.Message{m1},
+		reposts: []*repository.Repost{r2},
+	}
+	repo.onQuery = func() {
+		broadcaster.Broadcast(r2)
+	}
+
+	ids := runFeed(t, NewFeedHandler(broadcaster, repo), "", 2)
+
+	if want := []string{m1.ID(), r2.ID()}; !slices.Equal(ids, want) {
+		t.Fatalf("got %v, want %v", ids, want)
+	}
+}
+
+func TestGetFeedHomeOnlySendsFolloweesMessages(t *testing.T) {
+	followed1, other2, followed3 := newTestMessageBy(t, 1, "alice"), newTestMessageBy(t, 2, "bob"), newTestMessageBy(t, 3, "alice")
+	followed4, other5 := newTestMessageBy(t, 4, "alice"), newTestMessageBy(t, 5, "bob")
+	broadcaster := &fakeBroadcaster{}
+	repo := &fakeRepository{before: []*repository.Message{followed3, other2, followed1}}
+	if err := repo.Follow(context.Background(), "reader", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	repo.onQuery = func() {
+		broadcaster.Broadcast(other5)
+		broadcaster.Broadcast(followed4)
+		broadcaster.Broadcast(repository.NewMessageDeletion(other2.ID(), other2.UserID()))
+	}
+
+	ids := runFeedAs(t, NewFeedHandler(broadcaster, repo), "/api/feed?timeline=home", "reader", "", 3)
+
+	assertIDs(t, ids, followed1, followed3, followed4)
+}
+
+func TestGetFeedHomeRequiresAuthentication(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/feed?timeline=home", nil)
+	rec := httptest.NewRecorder()
+	NewFeedHandler(&fakeBroadcaster{}, &fakeRepository{}).GetFeed(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -1,38 +1,318 @@
 package handler
 
 import (
-	"encoding/json"
+	"errors"
+	"feed-api/internal/messaging"
 	"feed-api/internal/repository"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+	idempotencyKeyTTL    = 24 * time.Hour
+
+	// statusPending is reported for a message the broker has accepted. Its
+	// progress can be followed at the Location returned with it.
+	statusPending = "pending"
+
+	notDurableMessage = "Messages are not accepted while the producer does not wait for the broker"
 )
 
 type MessageHandler struct {
-	producer Producer[*repository.Message]
+	producer Producer[messaging.Eventable]
+	repo     Repository
 }
 
-func NewMessageHandler(p Producer[*repository.Message]) *MessageHandler {
+func NewMessageHandler(p Producer[messaging.Eventable], repo Repository) *MessageHandler {
 	return &MessageHandler{
 		producer: p,
+		repo:     repo,
 	}
 }
 
 func (m *MessageHandler) AddMessage(rw http.ResponseWriter, r *http.Request) {
 	type AddMessageRequest struct {
-		UserID  string `json:"user_id"`
-		Content string `json:"content"`
+		Content   string `json:"content"`
+		InReplyTo string `json:"in_reply_to"`
+	}
+	authorID := principalID(r)
+	if authorID == "" {
+		writeError(rw, unauthorized("Authentication required"))
+		return
 	}
+	// A message is only acknowledged once the broker has it; other delivery
+	// modes could drop it after the 202.
+	if !m.producer.Durable() {
+		writeError(rw, unavailable(notDurableMessage))
+		return
+	}
+
 	var request AddMessageRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(rw, "Invalid request body", http.StatusBadRequest)
+	if apiErr := decodeJSON(rw, r, &request); apiErr != nil {
+		writeError(rw, apiErr)
 		return
 	}
+	if apiErr := validateContent(request.Content); apiErr != nil {
+		writeError(rw, apiErr)
+		return
+	}
+
+	message := repository.NewMessage(authorID, request.Content)
+	if request.InReplyTo != "" {
+		if apiErr := m.validateParent(r, request.InReplyTo); apiErr != nil {
+			writeError(rw, apiErr)
+			return
+		}
+		message = repository.NewReply(authorID, request.Content, request.InReplyTo)
+	}
+
+	replayed := false
+	if key := r.Header.Get(idempotencyKeyHeader); key != "" {
+		if apiErr := validateIdempotencyKey(key); apiErr != nil {
+			writeError(rw, apiErr)
+			return
+		}
+		stored, reserved, err := m.repo.ReserveIdempotencyKey(r.Context(), key, message, idempotencyKeyTTL)
+		if err != nil {
+			log.Println("Error reserving idempotency key:", err)
+			writeError(rw, internalError("Failed to create message"))
+			return
+		}
+		if !reserved && (stored.Content() != message.Content() || stored.InReplyTo() != message.InReplyTo()) {
+			writeError(rw, &apiError{
+				Status:  http.StatusUnprocessableEntity,
+				Code:    codeIdempotencyKeyReused,
+				Field:   idempotencyKeyHeader,
+				Message: "Idempotency-Key was already used with a different request",
+			})
+			return
+		}
+		// On replay the original message is published again under its
+		// original id. The worker saves it idempotently, so a retry after a
+		// lost response cannot create a duplicate post.
+		message = stored
+		replayed = !reserved
+	}
 
-	message := repository.NewMessage(request.UserID, request.Content)
+	if err := m.repo.MarkQueued(r.Context(), message); err != nil {
+		log.Println("Error recording message status:", err)
+		writeError(rw, internalError("Failed to create message"))
+		return
+	}
 
 	if err := m.producer.Publish(r.Context(), message); err != nil {
-		http.Error(rw, "Failed to publish message", http.StatusInternalServerError)
+		if markErr := m.repo.MarkFailed(r.Context(), message, err.Error()); markErr != nil {
+			log.Println("Error recording message status:", markErr)
+		}
+		writeError(rw, internalError("Failed to publish message"))
+		return
+	}
+
+	if replayed {
+		rw.Header().Set("Idempotent-Replayed", "true")
+	}
+	rw.Header().Set("Location", "/api/messages/"+message.ID())
+	writeJSON(rw, http.StatusAccepted, newMessageStatusResponse(message, statusPending))
+}
+
+func (m *MessageHandler) GetMessage(rw http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := uuid.Validate(id); err != nil {
+		writeError(rw, badRequest("id", "Message id must be a UUID"))
+		return
+	}
+
+	message, status, err := m.repo.GetMessageStatus(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		writeError(rw, notFound("Message not found"))
+		return
+	}
+	if err != nil {
+		log.Println("Error fetching message status:", err)
+		writeError(rw, internalError("Failed to fetch message"))
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, newMessageStatusResponse(message, string(status)))
+}
+
+// validateParent checks that the message being replied to is persisted and
+// not deleted.
+func (m *MessageHandler) validateParent(r *http.Request, id string) *apiError {
+	if err := uuid.Validate(id); err != nil {
+		return validationFailed("in_reply_to", "in_reply_to must be a message id")
+	}
+	_, status, err := m.repo.GetMessageStatus(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && status != repository.StatusPersisted) {
+		return validationFailed("in_reply_to", "Message to reply to does not exist")
+	}
+	if err != nil {
+		log.Println("Error fetching message status:", err)
+		return internalError("Failed to create message")
+	}
+	return nil
+}
+
+// DeleteMessage soft-deletes one of the caller's messages. The deletion goes
+// through the pipeline like a new message, so it is accepted, not applied.
+func (m *MessageHandler) DeleteMessage(rw http.ResponseWriter, r *http.Request) {
+	message, apiErr := m.ownMessage(r)
+	if apiErr != nil {
+		writeError(rw, apiErr)
+		return
+	}
+
+	m.publishChange(rw, r, repository.NewMessageDeletion(message.ID(), message.UserID()))
+}
+
+// EditMessage replaces the content of one of the caller's messages.
+func (m *MessageHandler) EditMessage(rw http.ResponseWriter, r *http.Request) {
+	type EditMessageRequest struct {
+		Content string `json:"content"`
+	}
+	message, apiErr := m.ownMessage(r)
+	if apiErr != nil {
+		writeError(rw, apiErr)
+		return
+	}
+
+	var request EditMessageRequest
+	if apiErr = decodeJSON(rw, r, &request); apiErr != nil {
+		writeError(rw, apiErr)
+		return
+	}
+	if apiErr = validateContent(request.Content); apiErr != nil {
+		writeError(rw, apiErr)
+		return
+	}
+
+	m.publishChange(rw, r, repository.NewMessageEdit(message.ID(), message.UserID(), request.Content))
+}
+
+// ownMessage loads the persisted message named in the path and checks that
+// the caller wrote it.
+func (m *MessageHandler) ownMessage(r *http.Request) (*repository.Message, *apiError) {
+	authorID := principalID(r)
+	if authorID == "" {
+		return nil, unauthorized("Authentication required")
+	}
+	if !m.producer.Durable() {
+		return nil, unavailable(notDurableMessage)
+	}
+
+	id := r.PathValue("id")
+	if err := uuid.Validate(id); err != nil {
+		return nil, badRequest("id", "Message id must be a UUID")
+	}
+
+	message, status, err := m.repo.GetMessageStatus(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || status == repository.StatusDeleted {
+		return nil, notFound("Message not found")
+	}
+	if err != nil {
+		log.Println("Error fetching message status:", err)
+		return nil, internalError("Failed to fetch message")
+	}
+	if message.UserID() != authorID {
+		return nil, forbidden("Only the author can change a message")
+	}
+	if status != repository.StatusPersisted {
+		return nil, conflict("Message is not persisted yet")
+	}
+	return message, nil
+}
+
+func (m *MessageHandler) publishChange(rw http.ResponseWriter, r *http.Request, change *repository.MessageChange) {
+	if err := m.producer.Publish(r.Context(), change); err != nil {
+		writeError(rw, internalError("Failed to publish change"))
 		return
 	}
 
-	rw.WriteHeader(http.StatusAccepted)
+	type ChangeResponse struct {
+		ID       string `json:"id"`
+		ChangeID string `json:"change_id"`
+		Status   string `json:"status"`
+	}
+	rw.Header().Set("Location", "/api/messages/"+change.MessageID())
+	writeJSON(rw, http.StatusAccepted, ChangeResponse{
+		ID:       change.MessageID(),
+		ChangeID: change.ID(),
+		Status:   statusPending,
+	})
+}
+
+type messageStatusResponse struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Content   string    `json:"content,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	EditedAt  time.Time `json:"edited_at,omitzero"`
+	InReplyTo string    `json:"in_reply_to,omitempty"`
+	Status    string    `json:"status"`
+}
+
+func newMessageStatusResponse(msg *repository.Message, status string) messageStatusResponse {
+	return messageStatusResponse{
+		ID:        msg.ID(),
+		UserID:    msg.UserID(),
+		Content:   msg.Content(),
+		CreatedAt: msg.CreatedAt(),
+		EditedAt:  msg.EditedAt(),
+		InReplyTo: msg.InReplyTo(),
+		Status:    status,
+	}
+}
+
+func (m *MessageHandler) GetMessages(rw http.ResponseWriter, r *http.Request) {
+	before, err := repository.DecodeCursor(r.URL.Query().Get("before"))
+	if err != nil {
+		writeError(rw, badRequest("before", "Invalid cursor"))
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(rw, badRequest("limit", "Limit must be a positive integer"))
+		return
+	}
+
+	messages, err := m.repo.GetMessagesBefore(r.Context(), before, limit)
+	if err != nil {
+		log.Println("Error fetching messages:", err)
+		writeError(rw, internalError("Failed to fetch messages"))
+		return
+	}
+
+	type GetMessagesResponse struct {
+		Messages   []*repository.Message `json:"messages"`
+		NextCursor string                `json:"next_cursor,omitempty"`
+	}
+	response := GetMessagesResponse{
+		Messages: messages,
+	}
+	if len(messages) == limit {
+		response.NextCursor = messages[len(messages)-1].Cursor().Encode()
+	}
+
+	writeJSON(rw, http.StatusOK, response)
+}
+
+func parseLimit(raw string) (int, error) {
+	if raw == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return min(limit, maxPageLimit), nil
 }
@@ -0,0 +1,470 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"feed-api/internal/auth"
+	"feed-api/internal/messaging"
+	"feed-api/internal/repository"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProducer records what it publishes. Its zero mode is DeliverSync.
+type fakeProducer struct {
+	mode      messaging.DeliveryMode
+	published []messaging.Eventable
+}
+
+func (p *fakeProducer) Publish(ctx context.Context, data messaging.Eventable) error {
+	p.published = append(p.published, data)
+	return nil
+}
+
+func (p *fakeProducer) Durable() bool {
+	return p.mode == "" || p.mode.Durable()
+}
+
+func (p *fakeProducer) Close() error {
+	return nil
+}
+
+// serveMessage calls handle as userID, or unauthenticated if userID is
+// empty, with id as the path's message id.
+func serveMessage(handle http.HandlerFunc, method, id, userID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/messages/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", id)
+	if userID != "" {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: userID}))
+	}
+	rec := httptest.NewRecorder()
+	handle(rec, req)
+	return rec
+}
+
+func TestChangeMessage(t *testing.T) {
+	persisted := repository.NewMessage("user-1", "hello")
+	queued := repository.NewMessage("user-1", "on its way")
+	deleted := repository.NewMessage("user-1", "gone")
+	missing := repository.NewMessage("user-1", "never saved")
+	repo := &fakeRepository{statuses: map[string]fakeStatus{
+		persisted.ID(): {persisted, repository.StatusPersisted},
+		queued.ID():    {queued, repository.StatusQueued},
+		deleted.ID():   {deleted, repository.StatusDeleted},
+	}}
+
+	tests := []struct {
+		name   string
+		id     string
+		userID string
+		status int
+		code   string
+	}{
+		{name: "author", id: persisted.ID(), userID: "user-1", status: http.StatusAccepted},
+		{name: "not the author", id: persisted.ID(), userID: "user-2", status: http.StatusForbidden, code: codeForbidden},
+		{name: "missing message", id: missing.ID(), userID: "user-1", status: http.StatusNotFound, code: codeNotFound},
+		{name: "deleted message", id: deleted.ID(), userID: "user-1", status: http.StatusNotFound, code: codeNotFound},
+		{name: "queued message", id: queued.ID(), userID: "user-1", status: http.StatusConflict, code: codeConflict},
+		{name: "invalid id", id: "not-a-uuid", userID: "user-1", status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "unauthenticated", id: persisted.ID(), status: http.StatusUnauthorized, code: codeUnauthorized},
+	}
+	for _, kind := range []repository.ChangeKind{repository.ChangeEdited, repository.ChangeDeleted} {
+		for _, tt := range tests {
+			t.Run(string(kind)+"/"+tt.name, func(t *testing.T) {
+				producer := &fakeProducer{}
+				handler := NewMessageHandler(producer, repo)
+
+				var rec *httptest.ResponseRecorder
+				if kind == repository.ChangeEdited {
+					rec = serveMessage(handler.EditMessage, http.MethodPatch, tt.id, tt.userID, `{"content":"edited"}`)
+				} else {
+					rec = serveMessage(handler.DeleteMessage, http.MethodDelete, tt.id, tt.userID, "")
+				}
+
+				if rec.Code != tt.status {
+					t.Fatalf("got status %d, want %d", rec.Code, tt.status)
+				}
+				if tt.code != "" {
+					if code := decodeErrorCode(t, rec); code != tt.code {
+						t.Fatalf("got error code %q, want %q", code, tt.code)
+					}
+					if len(producer.published) != 0 {
+						t.Fatal("published a rejected change")
+					}
+					return
+				}
+
+				if len(producer.published) != 1 {
+					t.Fatalf("published %d events, want 1", len(producer.published))
+				}
+				change, ok := producer.published[0].(*repository.MessageChange)
+				if !ok || change.Kind() != kind || change.MessageID() != tt.id || change.UserID() != "user-1" {
+					t.Fatalf("published %#v, want the %s change of the message", producer.published[0], kind)
+				}
+				if kind == repository.ChangeEdited && change.Content() != "edited" {
+					t.Fatalf("published content %q, want edited", change.Content())
+				}
+				if got := rec.Header().Get("Location"); got != "/api/messages/"+tt.id {
+					t.Fatalf("got Location %q", got)
+				}
+			})
+		}
+	}
+}
+
+// postMessage posts body as userID with the given Idempotency-Key and returns
+// the response and the id of the accepted message.
+func postMessage(t *testing.T, handler *MessageHandler, userID, key, body string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{UserID: userID}))
+	rec := httptest.NewRecorder()
+	handler.AddMessage(rec, req)
+	if rec.Code != http.StatusAccepted {
+		return rec, ""
+	}
+
+	var response messageStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return rec, response.ID
+}
+
+func TestGetMessage(t *testing.T) {
+	queued := repository.NewMessage("user-1", "on its way")
+	persisted := repository.NewMessage("user-1", "hello")
+	failed := repository.NewMessage("user-1", "lost")
+	missing := repository.NewMessage("user-1", "never sent")
+	handler := NewMessageHandler(&fakeProducer{}, &fakeRepository{statuses: map[string]fakeStatus{
+		queued.ID():    {queued, repository.StatusQueued},
+		persisted.ID(): {persisted, repository.StatusPersisted},
+		failed.ID():    {failed, repository.StatusFailed},
+	}})
+
+	tests := []struct {
+		name       string
+		id         string
+		wantCode   int
+		wantStatus repository.DeliveryStatus
+		wantError  string
+	}{
+		{"pending", queued.ID(), http.StatusOK, repository.StatusQueued, ""},
+		{"saved", persisted.ID(), http.StatusOK, repository.StatusPersisted, ""},
+		{"failed", failed.ID(), http.StatusOK, repository.StatusFailed, ""},
+		{"not found", missing.ID(), http.StatusNotFound, "", codeNotFound},
+		{"malformed id", "not-a-uuid", http.StatusBadRequest, "", codeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveMessage(handler.GetMessage, http.MethodGet, tt.id, "", "")
+			if rec.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantError != "" {
+				if code := decodeErrorCode(t, rec); code != tt.wantError {
+					t.Fatalf("got error code %q, want %q", code, tt.wantError)
+				}
+				return
+			}
+			var response messageStatusResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.ID != tt.id || response.Status != string(tt.wantStatus) {
+				t.Fatalf("got message %s with status %q, want %s with %q", response.ID, response.Status, tt.id, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAddMessageAcknowledgesOnlyDurableDeliveryModes(t *testing.T) {
+	tests := []struct {
+		mode messaging.DeliveryMode
+		want int
+	}{
+		{messaging.DeliverSync, http.StatusAccepted},
+		{messaging.DeliverAsync, http.StatusServiceUnavailable},
+		{messaging.DeliverAsyncRetry, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			repo := &fakeRepository{}
+			producer := &fakeProducer{mode: tt.mode}
+			handler := NewMessageHandler(producer, repo)
+
+			rec, id := postMessage(t, handler, "user-1", "", `{"content":"hello"}`)
+			if rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusAccepted {
+				if code := decodeErrorCode(t, rec); code != codeUnavailable {
+					t.Fatalf("got error code %q, want %q", code, codeUnavailable)
+				}
+				if len(producer.published) != 0 {
+					t.Fatal("published a message that was refused")
+				}
+				return
+			}
+			if rec.Header().Get("Location") != "/api/messages/"+id {
+				t.Fatalf("got Location %q for message %s", rec.Header().Get("Location"), id)
+			}
+			if len(producer.published) != 1 {
+				t.Fatalf("published %d events, want 1", len(producer.published))
+			}
+
+			// Edits are acknowledged under the same rule.
+			persisted := repository.NewMessage("user-1", "hello")
+			repo.statuses = map[string]fakeStatus{persisted.ID(): {persisted, repository.StatusPersisted}}
+			producer.mode = messaging.DeliverAsync
+			rec = serveMessage(handler.EditMessage, http.MethodPatch, persisted.ID(), "user-1", `{"content":"edited"}`)
+			if rec.Code != http.StatusServiceUnavailable || len(producer.published) != 1 {
+				t.Fatalf("got status %d and %d events for an async edit, want %d and 1", rec.Code, len(producer.published), http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+func TestAddMessageRejectsRepliesToUnavailableParents(t *testing.T) {
+	persisted := repository.NewMessage("user-1", "hello")
+	queued := repository.NewMessage("user-1", "on its way")
+	deleted := repository.NewMessage("user-1", "gone")
+	missing := repository.NewMessage("user-1", "never sent")
+	repo := &fakeRepository{statuses: map[string]fakeStatus{
+		persisted.ID(): {persisted, repository.StatusPersisted},
+		queued.ID():    {queued, repository.StatusQueued},
+		deleted.ID():   {deleted, repository.StatusDeleted},
+	}}
+
+	tests := []struct {
+		name   string
+		parent string
+		want   int
+	}{
+		{"persisted", persisted.ID(), http.StatusAccepted},
+		{"deleted", deleted.ID(), http.StatusUnprocessableEntity},
+		{"queued", queued.ID(), http.StatusUnprocessableEntity},
+		{"not found", missing.ID(), http.StatusUnprocessableEntity},
+		{"not a uuid", "42", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := &fakeProducer{}
+			body := fmt.Sprintf(`{"content":"a reply","in_reply_to":%q}`, tt.parent)
+			rec, _ := postMessage(t, NewMessageHandler(producer, repo), "user-2", "", body)
+			if rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusAccepted {
+				return
+			}
+			if code := decodeErrorCode(t, rec); code != codeValidationFailed {
+				t.Fatalf("got error code %q, want %q", code, codeValidationFailed)
+			}
+			if len(producer.published) != 0 {
+				t.Fatal("published a reply to an unavailable parent")
+			}
+		})
+	}
+}
+
+func TestAddMessageWithIdempotencyKey(t *testing.T) {
+	repo := &fakeRepository{}
+	producer := &fakeProducer{}
+	handler := NewMessageHandler(producer, repo)
+
+	rec, id := postMessage(t, handler, "user-1", "key-1", `{"content":"hello"}`)
+	if id == "" || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("got status %d, replayed %q, want a new message", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+	}
+
+	// The replay publishes the original message again under its id.
+	rec, replayedID := postMessage(t, handler, "user-1", "key-1", `{"content":"hello"}`)
+	if replayedID != id {
+		t.Fatalf("got message %q, want the original %s", replayedID, id)
+	}
+	if rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("replay is not marked as replayed")
+	}
+	if len(producer.published) != 2 || producer.published[1].(*repository.Message).ID() != id {
+		t.Fatalf("published %d events, want the original message twice", len(producer.published))
+	}
+
+	rec, _ = postMessage(t, handler, "user-1", "key-1", `{"content":"something else"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got status %d for a different body, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if code := decodeErrorCode(t, rec); code != codeIdempotencyKeyReused {
+		t.Fatalf("got error code %q, want %q", code, codeIdempotencyKeyReused)
+	}
+	if len(producer.published) != 2 {
+		t.Fatal("published a request that reused a key")
+	}
+
+	// Keys are scoped to their user.
+	if _, otherID := postMessage(t, handler, "user-2", "key-1", `{"content":"hello"}`); otherID == "" || otherID == id {
+		t.Fatalf("got message %q for another user's key, want a new message", otherID)
+	}
+
+	// Once the key expires it starts a new message, whatever the body.
+	expired := repo.keys[[2]string{"user-1", "key-1"}]
+	expired.expiresAt = time.Now().Add(-time.Second)
+	repo.keys[[2]string{"user-1", "key-1"}] = expired
+	rec, freshID := postMessage(t, handler, "user-1", "key-1", `{"content":"something else"}`)
+	if freshID == "" || freshID == id || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("got status %d and message %q after the key expired, want a new message", rec.Code, freshID)
+	}
+}
+
+func TestAddMessageRejectsInvalidIdempotencyKeys(t *testing.T) {
+	for name, key := range map[string]string{
+		"too long":  strings.Repeat("k", maxIdempotencyKeyLen+1),
+		"space":     "my key",
+		"non-ascii": "clé",
+		"control":   "key\x7f",
+	} {
+		t.Run(name, func(t *testing.T) {
+			producer := &fakeProducer{}
+			rec, _ := postMessage(t, NewMessageHandler(producer, &fakeRepository{}), "user-1", key, `{"content":"hello"}`)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("got status %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if len(producer.published) != 0 {
+				t.Fatal("published a request with an invalid key")
+			}
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{"", defaultPageLimit, false},
+		{"1", 1, false},
+		{"20", 20, false},
+		{strconv.Itoa(maxPageLimit), maxPageLimit, false},
+		{strconv.Itoa(maxPageLimit + 1), maxPageLimit, false},
+		{"0", 0, true},
+		{"-5", 0, true},
+		{"ten", 0, true},
+		{"1.5", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLimit(tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseLimit(%q) = %d, %v; want %d, error %t", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// getMessages calls GetMessages with query and decodes the page it returns.
+func getMessages(t *testing.T, handler *MessageHandler, query string) (*httptest.ResponseRecorder, []string, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.GetMessages(rec, httptest.NewRequest(http.MethodGet, "/api/messages?"+query, nil))
+	if rec.Code != http.StatusOK {
+		return rec, nil, ""
+	}
+	var response struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, msg := range response.Messages {
+		ids = append(ids, msg.ID)
+	}
+	return rec, ids, response.NextCursor
+}
+
+func TestGetMessagesRejectsInvalidParameters(t *testing.T) {
+	valid := repository.Cursor{CreatedAt: time.Now().UTC(), ID: "00000000-0000-0000-0000-000000000001"}.Encode()
+
+	tests := []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"garbled cursor", "before=garbage!", "before"},
+		{"truncated cursor", "before=" + valid[:len(valid)-4], "before"},
+		{"cursor with a character swapped", "before=" + strings.Replace(valid, valid[:1], "*", 1), "before"},
+		{"cursor without id", "before=" + base64.RawURLEncoding.EncodeToString([]byte("2025-01-01T00:00:00Z|")), "before"},
+		{"zero limit", "limit=0", "limit"},
+		{"negative limit", "limit=-1", "limit"},
+		{"non-numeric limit", "limit=all", "limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _, _ := getMessages(t, NewMessageHandler(&fakeProducer{}, &fakeRepository{}), tt.query)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("got status %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			var body struct {
+				Error apiError `json:"error"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error.Field != tt.field {
+				t.Fatalf("got field %q, want %q", body.Error.Field, tt.field)
+			}
+		})
+	}
+}
+
+func TestGetMessagesPagesThroughEqualTimestamps(t *testing.T) {
+	// Five messages share a created_at, so only the id orders them.
+	var messages []*repository.Message
+	for n := 5; n >= 1; n-- {
+		payload := fmt.Sprintf(`{"id":"00000000-0000-0000-0000-%012d","user_id":"user-1","content":"same instant","created_at":"2025-01-01T00:00:00Z"}`, n)
+		var msg repository.Message
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, &msg)
+	}
+	handler := NewMessageHandler(&fakeProducer{}, &fakeRepository{before: messages})
+
+	var (
+		seen  []string
+		query = "limit=2"
+	)
+	for range len(messages) {
+		rec, ids, next := getMessages(t, handler, query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d for %q", rec.Code, query)
+		}
+		seen = append(seen, ids...)
+		if next == "" {
+			break
+		}
+		query = "limit=2&before=" + next
+	}
+
+	if len(seen) != len(messages) {
+		t.Fatalf("got %d messages across pages, want %d", len(seen), len(messages))
+	}
+	for i, msg := range messages {
+		if seen[i] != msg.ID() {
+			t.Fatalf("message %d is %s, want %s", i, seen[i], msg.ID())
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"feed-api/internal/auth"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// requireAuth rejects requests without a valid bearer token and injects the
+// authenticated principal into the request context. The token is read from the
+// Authorization header, or from the access_token query parameter for
+// EventSource clients, which cannot set headers.
+func requireAuth(verifier TokenVerifier, next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(rw, unauthorized("Missing bearer token"))
+			return
+		}
+
+		principal, err := verifier.Verify(token)
+		if err != nil {
+			log.Println("Rejected token:", err)
+			rw.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(rw, unauthorized("Invalid bearer token"))
+			return
+		}
+
+		next(rw, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// requireAdmin is requireAuth restricted to the given user ids.
+func requireAdmin(verifier TokenVerifier, admins map[string]struct{}, next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(verifier, func(rw http.ResponseWriter, r *http.Request) {
+		if _, ok := admins[principalID(r)]; !ok {
+			writeError(rw, forbidden("Admin access required"))
+			return
+		}
+		next(rw, r)
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// principalID returns the id of the authenticated user, or "" outside requireAuth.
+func principalID(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return p.UserID
+	}
+	return ""
+}
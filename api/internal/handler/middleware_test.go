@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"encoding/json"
+	"feed-api/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeVerifier accepts the tokens it maps to a user id.
+type fakeVerifier map[string]string
+
+func (v fakeVerifier) Verify(token string) (*auth.Principal, error) {
+	userID, ok := v[token]
+	if !ok {
+		return nil, auth.ErrBadSignature
+	}
+	return &auth.Principal{UserID: userID}, nil
+}
+
+// echoPrincipal writes the authenticated user id.
+func echoPrincipal(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, map[string]string{"user_id": principalID(r)})
+}
+
+func decodeErrorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error *apiError `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error == nil {
+		t.Fatal("response has no error envelope")
+	}
+	return body.Error.Code
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		target        string
+		want          string
+	}{
+		{"header", "Bearer token-1", "/", "token-1"},
+		{"scheme is case-insensitive", "bearer token-1", "/", "token-1"},
+		{"header wins over query", "Bearer token-1", "/?access_token=token-2", "token-1"},
+		{"query fallback", "", "/?access_token=token-2", "token-2"},
+		{"other scheme", "Basic dXNlcjpwYXNz", "/?access_token=token-2", ""},
+		{"no scheme", "token-1", "/", ""},
+		{"none", "", "/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if got := bearerToken(req); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	handler := requireAuth(fakeVerifier{"good": "user-1"}, echoPrincipal)
+
+	tests := []struct {
+		name          string
+		authorization string
+		target        string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{"valid header", "Bearer good", "/", http.StatusOK, ""},
+		{"valid access_token", "", "/?access_token=good", http.StatusOK, ""},
+		{"missing", "", "/", http.StatusUnauthorized, "Bearer"},
+		{"invalid", "Bearer forged", "/", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"invalid access_token", "", "/?access_token=forged", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Fatalf("got WWW-Authenticate %q, want %q", got, tt.wantChallenge)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if code := decodeErrorCode(t, rec); code != codeUnauthorized {
+					t.Fatalf("got error code %q, want %q", code, codeUnauthorized)
+				}
+				return
+			}
+			var body map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body["user_id"] != "user-1" {
+				t.Fatalf("got principal %q, want user-1", body["user_id"])
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	verifier := fakeVerifier{"admin": "user-1", "user": "user-2"}
+	handler := requireAdmin(verifier, map[string]struct{}{"user-1": {}}, echoPrincipal)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"admin", "admin", http.StatusOK, ""},
+		{"not an admin", "user", http.StatusForbidden, codeForbidden},
+		{"unauthenticated", "", http.StatusUnauthorized, codeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/dlq/replay", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantCode != "" {
+				if code := decodeErrorCode(t, rec); code != tt.wantCode {
+					t.Fatalf("got error code %q, want %q", code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"feed-api/internal/repository"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type ReactionHandler struct {
+	repo Repository
+}
+
+func NewReactionHandler(repo Repository) *ReactionHandler {
+	return &ReactionHandler{
+		repo: repo,
+	}
+}
+
+func (h *ReactionHandler) Like(rw http.ResponseWriter, r *http.Request) {
+	h.react(rw, r, h.repo.Like, "like message")
+}
+
+func (h *ReactionHandler) Unlike(rw http.ResponseWriter, r *http.Request) {
+	h.react(rw, r, h.repo.Unlike, "unlike message")
+}
+
+func (h *ReactionHandler) Repost(rw http.ResponseWriter, r *http.Request) {
+	h.react(rw, r, h.repo.Repost, "repost message")
+}
+
+func (h *ReactionHandler) Unrepost(rw http.ResponseWriter, r *http.Request) {
+	h.react(rw, r, h.repo.Unrepost, "undo repost")
+}
+
+// react applies a reaction of the authenticated user to the message in the
+// path. Reactions are idempotent, so repeating one also returns 204.
+func (h *ReactionHandler) react(
+	rw http.ResponseWriter,
+	r *http.Request,
+	apply func(ctx context.Context, messageID, userID string) error,
+	action string,
+) {
+	userID := principalID(r)
+	if userID == "" {
+		writeError(rw, unauthorized("Authentication required"))
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := uuid.Validate(id); err != nil {
+		writeError(rw, badRequest("id", "Message id must be a UUID"))
+		return
+	}
+
+	err := apply(r.Context(), id, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		writeError(rw, notFound("Message not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error trying to %s: %v", action, err)
+		writeError(rw, internalError("Failed to "+action))
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -1,20 +1,49 @@
 package handler
 
 import (
-	"feed-api/internal/repository"
+	"expvar"
+	"feed-api/internal/messaging"
 	"net/http"
 )
 
-func NewRouter(producer Producer[*repository.Message], repo Repository, broadcaster *Broadcaster) http.Handler {
+func NewRouter(
+	producer Producer[messaging.Eventable],
+	repo Repository,
+	broadcaster *Broadcaster,
+	verifier TokenVerifier,
+	deadLetters DeadLetterStore,
+	admins map[string]struct{},
+) http.Handler {
 	router := http.NewServeMux()
 
 	healthHandler := NewHealthHandler()
 	feedHandler := NewFeedHandler(broadcaster, repo)
-	messagesHandler := NewMessageHandler(producer)
+	messagesHandler := NewMessageHandler(producer, repo)
+	userHandler := NewUserHandler(repo)
+	reactionHandler := NewReactionHandler(repo)
+	adminHandler := NewAdminHandler(deadLetters)
 
 	router.HandleFunc("GET /api/health", healthHandler.CheckHealth)
-	router.HandleFunc("GET /api/feed", feedHandler.GetFeed)
-	router.HandleFunc("POST /api/messages", messagesHandler.AddMessage)
+	router.HandleFunc("GET /api/feed", requireAuth(verifier, feedHandler.GetFeed))
+	router.HandleFunc("GET /api/messages", messagesHandler.GetMessages)
+	router.HandleFunc("POST /api/messages", requireAuth(verifier, messagesHandler.AddMessage))
+	router.HandleFunc("GET /api/messages/{id}", messagesHandler.GetMessage)
+	router.HandleFunc("GET /api/messages/{id}/thread", messagesHandler.GetThread)
+	router.HandleFunc("PATCH /api/messages/{id}", requireAuth(verifier, messagesHandler.EditMessage))
+	router.HandleFunc("DELETE /api/messages/{id}", requireAuth(verifier, messagesHandler.DeleteMessage))
+	router.HandleFunc("GET /api/search", messagesHandler.Search)
+	router.HandleFunc("GET /api/tags/{tag}/messages", messagesHandler.GetTaggedMessages)
+	router.HandleFunc("POST /api/messages/{id}/like", requireAuth(verifier, reactionHandler.Like))
+	router.HandleFunc("DELETE /api/messages/{id}/like", requireAuth(verifier, reactionHandler.Unlike))
+	router.HandleFunc("POST /api/messages/{id}/repost", requireAuth(verifier, reactionHandler.Repost))
+	router.HandleFunc("DELETE /api/messages/{id}/repost", requireAuth(verifier, reactionHandler.Unrepost))
+	router.HandleFunc("POST /api/users/{id}/follow", requireAuth(verifier, userHandler.Follow))
+	router.HandleFunc("DELETE /api/users/{id}/follow", requireAuth(verifier, userHandler.Unfollow))
+	router.HandleFunc("GET /api/users/{id}/mentions", messagesHandler.GetMentions)
+	router.HandleFunc("GET /api/admin/dlq", requireAdmin(verifier, admins, adminHandler.ListDeadLetters))
+	router.HandleFunc("GET /api/admin/dlq/{partition}/{offset}", requireAdmin(verifier, admins, adminHandler.GetDeadLetter))
+	router.HandleFunc("POST /api/admin/dlq/replay", requireAdmin(verifier, admins, adminHandler.ReplayDeadLetters))
+	router.HandleFunc("GET /api/admin/vars", requireAdmin(verifier, admins, expvar.Handler().ServeHTTP))
 
 	return router
 }
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"feed-api/internal/repository"
+	"feed-api/internal/search"
+	"log"
+	"net/http"
+)
+
+// Search returns the messages matching the query in q, newest first, with the
+// same pagination as GetMessages. See search.Parse for the query syntax.
+func (m *MessageHandler) Search(rw http.ResponseWriter, r *http.Request) {
+	query, err := search.Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(rw, badRequest("q", "Invalid search query: "+err.Error()))
+		return
+	}
+
+	before, err := repository.DecodeCursor(r.URL.Query().Get("before"))
+	if err != nil {
+		writeError(rw, badRequest("before", "Invalid cursor"))
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(rw, badRequest("limit", "Limit must be a positive integer"))
+		return
+	}
+
+	messages, err := m.repo.SearchMessages(r.Context(), query, before, limit)
+	if err != nil {
+		log.Println("Error searching messages:", err)
+		writeError(rw, internalError("Failed to search messages"))
+		return
+	}
+
+	type SearchResponse struct {
+		Messages   []*repository.Message `json:"messages"`
+		NextCursor string                `json:"next_cursor,omitempty"`
+	}
+	response := SearchResponse{
+		Messages: messages,
+	}
+	if len(messages) == limit {
+		response.NextCursor = messages[len(messages)-1].Cursor().Encode()
+	}
+
+	writeJSON(rw, http.StatusOK, response)
+}
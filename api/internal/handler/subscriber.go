@@ -5,57 +5,82 @@ import (
 	"encoding/json"
 	"errors"
 	"feed-api/internal/messaging"
-	"feed-api/internal/repository"
+	"feed-api/internal/transport"
+	"fmt"
 	"log"
-	"time"
+	"os"
+)
+
+// GroupStrategy controls how the Subscriber reads its topic. Every API
+// replica must see every processed event, so replicas must not share a group.
+type GroupStrategy string
 
-	"github.com/segmentio/kafka-go"
+const (
+	// GroupPerInstance reads every partition without a consumer group,
+	// starting from the latest offset. Nothing is replayed after a restart
+	// and no group is left behind on the broker.
+	GroupPerInstance GroupStrategy = "instance"
+	// GroupPerHost names the group after the hostname, so a restarted
+	// container resumes from its last committed offset.
+	GroupPerHost GroupStrategy = "host"
 )
 
+// SubscriberGroupID builds the consumer group id for the given strategy. It
+// is empty for GroupPerInstance, which subscribes without a group.
+func SubscriberGroupID(strategy GroupStrategy, prefix string) (string, error) {
+	switch strategy {
+	case GroupPerInstance:
+		return "", nil
+	case GroupPerHost:
+		hostname, err := os.Hostname()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s-%s", prefix, hostname), nil
+	default:
+		return "", fmt.Errorf("unknown subscriber group strategy %q", strategy)
+	}
+}
+
 type Subscriber struct {
-	reader      *kafka.Reader
-	broadcaster *Broadcaster
+	subscription transport.Subscription
+	broadcaster  *Broadcaster
 }
 
-func NewSubscriber(brokers []string, topic, groupID string, b *Broadcaster) *Subscriber {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: brokers,
+func NewSubscriber(t transport.Transport, topic, groupID string, b *Broadcaster) *Subscriber {
+	subscription := t.Subscribe(transport.SubscriptionConfig{
 		Topic:   topic,
 		GroupID: groupID,
+		// Without a group, or with a new one, there is no committed offset;
+		// start from live events since history is served from the database.
+		StartOffset: transport.StartLatest,
 	})
 	return &Subscriber{
-		reader:      reader,
-		broadcaster: b,
+		subscription: subscription,
+		broadcaster:  b,
 	}
 }
 
 func (s *Subscriber) Run(ctx context.Context) {
 	log.Println("Notification subscriber started")
-	defer s.reader.Close()
+	defer s.subscription.Close()
 
 	for {
-		msg, err := s.reader.FetchMessage(ctx)
+		msg, err := s.subscription.Fetch(ctx)
 		if err != nil {
-			if errors.Is(err, context.Canceled) {
+			if errors.Is(err, context.Canceled) || errors.Is(err, transport.ErrClosed) {
 				log.Println("Notification subscriber stopped")
 				return
 			}
 
-			var kafkaErr kafka.Error
-			if errors.As(err, &kafkaErr) && errors.Is(kafkaErr, kafka.NotCoordinatorForGroup) {
-				log.Println("KafkaConsumer not coordinator for group, retrying...")
-				time.Sleep(3 * time.Second)
-				continue
-			}
-
 			log.Println("Error fetching message:", err)
 			continue
 		}
 
-		var event messaging.Event[*repository.Message]
+		var event messaging.Event[messaging.Eventable]
 		if err = json.Unmarshal(msg.Value, &event); err != nil {
 			log.Println("Error unmarshalling message:", err)
-			if commitErr := s.reader.CommitMessages(ctx, msg); commitErr != nil {
+			if commitErr := s.subscription.Commit(ctx, msg); commitErr != nil {
 				log.Println("Error committing poison message:", commitErr)
 			}
 			continue
@@ -65,7 +90,7 @@ func (s *Subscriber) Run(ctx context.Context) {
 
 		s.broadcaster.Broadcast(&event)
 
-		err = s.reader.CommitMessages(ctx, msg)
+		err = s.subscription.Commit(ctx, msg)
 		if err != nil {
 			log.Println("Error committing message:", err)
 		}
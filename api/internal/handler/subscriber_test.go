@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"feed-api/internal/messaging"
+	"feed-api/internal/repository"
+	"feed-api/internal/transport"
+	"feed-api/internal/transport/memory"
+	"testing"
+	"time"
+)
+
+func publishProcessed(t *testing.T, publisher transport.Publisher, msgs ...*repository.Message) {
+	t.Helper()
+	for _, msg := range msgs {
+		value, err := json.Marshal(messaging.NewEventMessage[messaging.Eventable](msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = publisher.Publish(context.Background(), transport.Message{Topic: "events-processed", Key: []byte(msg.ID()), Value: value})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSubscriberPerInstanceReadsEveryPartitionFromLatest(t *testing.T) {
+	// The subscriber decodes events of every type, as the API does.
+	repository.RegisterEvents(messaging.DefaultRegistry)
+
+	groupID, err := SubscriberGroupID(GroupPerInstance, "feed-subscriber")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupID != "" {
+		t.Fatalf("got group %q, want none", groupID)
+	}
+
+	broker := memory.New(4)
+	publisher := broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey})
+	earlier := newTestMessage(t, 0)
+	publishProcessed(t, publisher, earlier)
+
+	b, client := newTestBroadcaster(t)
+	subscriber := NewSubscriber(broker, "events-processed", groupID, b)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		subscriber.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	var live []*repository.Message
+	for n := 1; n <= 8; n++ {
+		live = append(live, newTestMessage(t, n))
+	}
+	publishProcessed(t, publisher, live...)
+
+	seen := make(map[string]bool)
+	deadline := time.After(2 * time.Second)
+	for n := 0; n < len(live); n++ {
+		select {
+		case event := <-client.Events():
+			seen[event.Data().(*repository.Message).ID()] = true
+		case <-deadline:
+			t.Fatalf("received %d of %d live events", len(seen), len(live))
+		}
+	}
+	for _, msg := range live {
+		if !seen[msg.ID()] {
+			t.Fatalf("missing live message %s", msg.ID())
+		}
+	}
+	if seen[earlier.ID()] {
+		t.Fatal("replayed a message published before the subscriber started")
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"feed-api/internal/repository"
+	"feed-api/internal/tags"
+	"log"
+	"net/http"
+)
+
+// GetTaggedMessages returns the messages with a hashtag, newest first, with
+// the same pagination as GetMessages. The tag is matched case-insensitively
+// and may be given with or without its leading '#'.
+func (m *MessageHandler) GetTaggedMessages(rw http.ResponseWriter, r *http.Request) {
+	tag, ok := tags.NormalizeHashtag(r.PathValue("tag"))
+	if !ok {
+		writeError(rw, badRequest("tag", "Invalid hashtag"))
+		return
+	}
+
+	before, err := repository.DecodeCursor(r.URL.Query().Get("before"))
+	if err != nil {
+		writeError(rw, badRequest("before", "Invalid cursor"))
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(rw, badRequest("limit", "Limit must be a positive integer"))
+		return
+	}
+
+	messages, err := m.repo.GetTaggedMessagesBefore(r.Context(), tag, before, limit)
+	if err != nil {
+		log.Println("Error fetching tagged messages:", err)
+		writeError(rw, internalError("Failed to fetch messages"))
+		return
+	}
+
+	type GetTaggedMessagesResponse struct {
+		Tag        string                `json:"tag"`
+		Messages   []*repository.Message `json:"messages"`
+		NextCursor string                `json:"next_cursor,omitempty"`
+	}
+	response := GetTaggedMessagesResponse{
+		Tag:      tag,
+		Messages: messages,
+	}
+	if len(messages) == limit {
+		response.NextCursor = messages[len(messages)-1].Cursor().Encode()
+	}
+
+	writeJSON(rw, http.StatusOK, response)
+}
+
+// GetMentions returns the messages mentioning a user, newest first, with the
+// same pagination as GetMessages.
+func (m *MessageHandler) GetMentions(rw http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+
+	before, err := repository.DecodeCursor(r.URL.Query().Get("before"))
+	if err != nil {
+		writeError(rw, badRequest("before", "Invalid cursor"))
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(rw, badRequest("limit", "Limit must be a positive integer"))
+		return
+	}
+
+	messages, err := m.repo.GetMentionsBefore(r.Context(), userID, before, limit)
+	if err != nil {
+		log.Println("Error fetching mentions:", err)
+		writeError(rw, internalError("Failed to fetch messages"))
+		return
+	}
+
+	type GetMentionsResponse struct {
+		UserID     string                `json:"user_id"`
+		Messages   []*repository.Message `json:"messages"`
+		NextCursor string                `json:"next_cursor,omitempty"`
+	}
+	response := GetMentionsResponse{
+		UserID:   userID,
+		Messages: messages,
+	}
+	if len(messages) == limit {
+		response.NextCursor = messages[len(messages)-1].Cursor().Encode()
+	}
+
+	writeJSON(rw, http.StatusOK, response)
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"feed-api/internal/repository"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetMentions(t *testing.T) {
+	var messages []*repository.Message // newest first
+	for n, content := range []string{"hi @bob", "hi @alice", "no mention", "@alice and @bob", "thanks @alice!"} {
+		payload := fmt.Sprintf(
+			`{"id":"00000000-0000-0000-0000-%012d","user_id":"carol","content":%q,"created_at":"%s"}`,
+			n, content, time.Date(2025, 1, 1, 0, 0, 10-n, 0, time.UTC).Format(time.RFC3339Nano),
+		)
+		var msg repository.Message
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, &msg)
+	}
+	handler := NewMessageHandler(&fakeProducer{}, &fakeRepository{before: messages})
+
+	var (
+		got   []string
+		query = "limit=2"
+	)
+	for range len(messages) {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/alice/mentions?"+query, nil)
+		req.SetPathValue("id", "alice")
+		rec := httptest.NewRecorder()
+		handler.GetMentions(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d for %q", rec.Code, query)
+		}
+		var response struct {
+			UserID   string `json:"user_id"`
+			Messages []struct {
+				ID string `json:"id"`
+			} `json:"messages"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.UserID != "alice" {
+			t.Fatalf("got user %q, want alice", response.UserID)
+		}
+		for _, msg := range response.Messages {
+			got = append(got, msg.ID)
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		query = "limit=2&before=" + response.NextCursor
+	}
+
+	assertIDs(t, got, messages[1], messages[3], messages[4])
+}
+
+func TestGetMentionsRejectsInvalidCursor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/users/alice/mentions?before=garbage!", nil)
+	req.SetPathValue("id", "alice")
+	rec := httptest.NewRecorder()
+	NewMessageHandler(&fakeProducer{}, &fakeRepository{}).GetMentions(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"errors"
+	"feed-api/internal/repository"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// threadMessage is a message of a conversation with the replies to it that
+// are on the same page.
+type threadMessage struct {
+	ID          string           `json:"id"`
+	UserID      string           `json:"user_id,omitempty"`
+	Content     string           `json:"content,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	EditedAt    time.Time        `json:"edited_at,omitzero"`
+	Deleted     bool             `json:"deleted,omitempty"`
+	InReplyTo   string           `json:"in_reply_to,omitempty"`
+	Depth       int              `json:"depth"`
+	ReplyCount  int              `json:"reply_count"`
+	LikeCount   int              `json:"like_count"`
+	RepostCount int              `json:"repost_count"`
+	Replies     []*threadMessage `json:"replies"`
+}
+
+func newThreadMessage(msg *repository.Message) *threadMessage {
+	node := &threadMessage{
+		ID:          msg.ID(),
+		UserID:      msg.UserID(),
+		Content:     msg.Content(),
+		CreatedAt:   msg.CreatedAt(),
+		EditedAt:    msg.EditedAt(),
+		InReplyTo:   msg.InReplyTo(),
+		Depth:       msg.Depth(),
+		ReplyCount:  msg.ReplyCount(),
+		LikeCount:   msg.LikeCount(),
+		RepostCount: msg.RepostCount(),
+		Replies:     []*threadMessage{},
+	}
+	if !msg.DeletedAt().IsZero() {
+		node.UserID = ""
+		node.Deleted = true
+	}
+	return node
+}
+
+// buildThread nests each message under the one it replies to. Messages come
+// oldest first, so a parent on the page always precedes its replies; replies
+// whose parent is on an earlier page are returned at the top level.
+func buildThread(messages []*repository.Message) []*threadMessage {
+	nodes := make(map[string]*threadMessage, len(messages))
+	roots := make([]*threadMessage, 0)
+	for _, msg := range messages {
+		node := newThreadMessage(msg)
+		nodes[node.ID] = node
+		if parent, ok := nodes[node.InReplyTo]; ok {
+			parent.Replies = append(parent.Replies, node)
+			continue
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+// GetThread returns the conversation the message belongs to as a tree, one
+// page of messages at a time in the order they were written.
+func (m *MessageHandler) GetThread(rw http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := uuid.Validate(id); err != nil {
+		writeError(rw, badRequest("id", "Message id must be a UUID"))
+		return
+	}
+
+	after, err := repository.DecodeCursor(r.URL.Query().Get("after"))
+	if err != nil {
+		writeError(rw, badRequest("after", "Invalid cursor"))
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(rw, badRequest("limit", "Limit must be a positive integer"))
+		return
+	}
+
+	messages, err := m.repo.GetThread(r.Context(), id, after, limit)
+	if errors.Is(err, repository.ErrNotFound) {
+		writeError(rw, notFound("Message not found"))
+		return
+	}
+	if err != nil {
+		log.Println("Error fetching thread:", err)
+		writeError(rw, internalError("Failed to fetch thread"))
+		return
+	}
+
+	type GetThreadResponse struct {
+		ConversationID string           `json:"conversation_id,omitempty"`
+		Messages       []*threadMessage `json:"messages"`
+		NextCursor     string           `json:"next_cursor,omitempty"`
+	}
+	response := GetThreadResponse{
+		Messages: buildThread(messages),
+	}
+	if len(messages) > 0 {
+		response.ConversationID = messages[0].ConversationID()
+	}
+	if len(messages) == limit {
+		response.NextCursor = messages[len(messages)-1].Cursor().Encode()
+	}
+
+	writeJSON(rw, http.StatusOK, response)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"feed-api/internal/repository"
+	"strings"
+	"testing"
+)
+
+func newTestReply(t *testing.T, n int, inReplyTo *repository.Message) *repository.Message {
+	t.Helper()
+	msg := newTestMessage(t, n)
+	raw, err := msg.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]any
+	if err = json.Unmarshal(raw, &payload); err != nil {
+		t.Fatal(err)
+	}
+	payload["in_reply_to"] = inReplyTo.ID()
+	if raw, err = json.Marshal(payload); err != nil {
+		t.Fatal(err)
+	}
+	var reply repository.Message
+	if err = json.Unmarshal(raw, &reply); err != nil {
+		t.Fatal(err)
+	}
+	return &reply
+}
+
+func TestBuildThreadNestsRepliesUnderTheirParents(t *testing.T) {
+	root := newTestMessage(t, 1)
+	a := newTestReply(t, 2, root)
+	b := newTestReply(t, 3, root)
+	aa := newTestReply(t, 4, a)
+	// The parent of orphan is on an earlier page.
+	orphan := newTestReply(t, 5, newTestMessage(t, 0))
+
+	roots := buildThread([]*repository.Message{root, a, b, aa, orphan})
+
+	var shape func(nodes []*threadMessage) string
+	shape = func(nodes []*threadMessage) string {
+		parts := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			part := node.ID[len(node.ID)-1:]
+			if len(node.Replies) > 0 {
+				part += "(" + shape(node.Replies) + ")"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " ")
+	}
+	if got, want := shape(roots), "1(2(4) 3) 5"; got != want {
+		t.Fatalf("got thread %s, want %s", got, want)
+	}
+}
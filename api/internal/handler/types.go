@@ -2,7 +2,11 @@ package handler
 
 import (
 	"context"
+	"feed-api/internal/auth"
+	"feed-api/internal/deadletter"
 	"feed-api/internal/repository"
+	"feed-api/internal/search"
+	"time"
 )
 
 type Eventable interface {
@@ -11,10 +15,52 @@ type Eventable interface {
 
 type Producer[T Eventable] interface {
 	Publish(ctx context.Context, data T) error
+	// Durable reports whether a successful Publish means the broker has the
+	// event.
+	Durable() bool
 	Close() error
 }
 
 type Repository interface {
 	SaveMessage(ctx context.Context, msg *repository.Message) error
-	GetAllMessages(ctx context.Context) ([]*repository.Message, error)
+	GetMessagesBefore(ctx context.Context, before repository.Cursor, limit int) ([]*repository.Message, error)
+	GetMessagesAfter(ctx context.Context, after repository.Cursor, limit int) ([]*repository.Message, error)
+	GetHomeTimelineBefore(ctx context.Context, followerID string, before repository.Cursor, limit int) ([]*repository.Message, error)
+	GetHomeTimelineAfter(ctx context.Context, followerID string, after repository.Cursor, limit int) ([]*repository.Message, error)
+	Follow(ctx context.Context, followerID, followeeID string) error
+	Unfollow(ctx context.Context, followerID, followeeID string) error
+	Like(ctx context.Context, messageID, userID string) error
+	Unlike(ctx context.Context, messageID, userID string) error
+	Repost(ctx context.Context, messageID, userID string) error
+	Unrepost(ctx context.Context, messageID, userID string) error
+	GetFollowees(ctx context.Context, followerID string) ([]string, error)
+	MarkQueued(ctx context.Context, msg *repository.Message) error
+	MarkFailed(ctx context.Context, msg *repository.Message, reason string) error
+	GetMessageStatus(ctx context.Context, id string) (*repository.Message, repository.DeliveryStatus, error)
+	GetTaggedMessagesBefore(ctx context.Context, tag string, before repository.Cursor, limit int) ([]*repository.Message, error)
+	GetTaggedMessagesAfter(ctx context.Context, tag string, after repository.Cursor, limit int) ([]*repository.Message, error)
+	GetMentionsBefore(ctx context.Context, userID string, before repository.Cursor, limit int) ([]*repository.Message, error)
+	GetRepostsBefore(ctx context.Context, before repository.Cursor, limit int) ([]*repository.Repost, error)
+	GetRepostsAfter(ctx context.Context, after repository.Cursor, limit int) ([]*repository.Repost, error)
+	GetHomeRepostsBefore(ctx context.Context, followerID string, before repository.Cursor, limit int) ([]*repository.Repost, error)
+	GetHomeRepostsAfter(ctx context.Context, followerID string, after repository.Cursor, limit int) ([]*repository.Repost, error)
+	SearchMessages(ctx context.Context, query search.Query, before repository.Cursor, limit int) ([]*repository.Message, error)
+	GetThread(ctx context.Context, id string, after repository.Cursor, limit int) ([]*repository.Message, error)
+	ReserveIdempotencyKey(ctx context.Context, key string, msg *repository.Message, ttl time.Duration) (*repository.Message, bool, error)
+}
+
+type ClientRegistry interface {
+	Register(client *Client)
+	Unregister(client *Client)
+}
+
+type TokenVerifier interface {
+	Verify(token string) (*auth.Principal, error)
+}
+
+type DeadLetterStore interface {
+	List(ctx context.Context, from deadletter.Position, limit int) ([]deadletter.Entry, *deadletter.Position, error)
+	Get(ctx context.Context, pos deadletter.Position) (*deadletter.Entry, error)
+	Replay(ctx context.Context, positions []deadletter.Position) (int, error)
+	ReplayAll(ctx context.Context) (int, error)
 }
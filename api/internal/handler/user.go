@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+)
+
+type UserHandler struct {
+	repo Repository
+}
+
+func NewUserHandler(repo Repository) *UserHandler {
+	return &UserHandler{
+		repo: repo,
+	}
+}
+
+func (u *UserHandler) Follow(rw http.ResponseWriter, r *http.Request) {
+	followerID, followeeID, ok := u.followPair(rw, r)
+	if !ok {
+		return
+	}
+
+	if err := u.repo.Follow(r.Context(), followerID, followeeID); err != nil {
+		log.Println("Error following user:", err)
+		writeError(rw, internalError("Failed to follow user"))
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (u *UserHandler) Unfollow(rw http.ResponseWriter, r *http.Request) {
+	followerID, followeeID, ok := u.followPair(rw, r)
+	if !ok {
+		return
+	}
+
+	if err := u.repo.Unfollow(r.Context(), followerID, followeeID); err != nil {
+		log.Println("Error unfollowing user:", err)
+		writeError(rw, internalError("Failed to unfollow user"))
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (u *UserHandler) followPair(rw http.ResponseWriter, r *http.Request) (followerID, followeeID string, ok bool) {
+	followerID = principalID(r)
+	if followerID == "" {
+		writeError(rw, unauthorized("Authentication required"))
+		return "", "", false
+	}
+
+	followeeID = r.PathValue("id")
+	if followeeID == followerID {
+		writeError(rw, badRequest("id", "Users cannot follow themselves"))
+		return "", "", false
+	}
+
+	return followerID, followeeID, true
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestFollow(t *testing.T) {
+	repo := &fakeRepository{}
+	handler := NewUserHandler(repo)
+
+	tests := []struct {
+		name     string
+		handle   http.HandlerFunc
+		userID   string
+		followee string
+		want     int
+		// wantFollowees are the users user-1 follows afterwards.
+		wantFollowees []string
+	}{
+		{"unauthenticated", handler.Follow, "", "user-2", http.StatusUnauthorized, nil},
+		{"yourself", handler.Follow, "user-1", "user-1", http.StatusBadRequest, nil},
+		{"follow", handler.Follow, "user-1", "user-2", http.StatusNoContent, []string{"user-2"}},
+		{"follow again", handler.Follow, "user-1", "user-2", http.StatusNoContent, []string{"user-2"}},
+		{"unfollow someone not followed", handler.Unfollow, "user-1", "user-3", http.StatusNoContent, []string{"user-2"}},
+		{"unfollow yourself", handler.Unfollow, "user-1", "user-1", http.StatusBadRequest, []string{"user-2"}},
+		{"unfollow", handler.Unfollow, "user-1", "user-2", http.StatusNoContent, nil},
+		{"unfollow again", handler.Unfollow, "user-1", "user-2", http.StatusNoContent, nil},
+	}
+	// The cases run in order: each starts from the follows the last one left.
+	for _, tt := range tests {
+		rec := serveMessage(tt.handle, http.MethodPost, tt.followee, tt.userID, "")
+		if rec.Code != tt.want {
+			t.Fatalf("%s: got status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if rec.Code == http.StatusBadRequest {
+			if code := decodeErrorCode(t, rec); code != codeInvalidRequest {
+				t.Fatalf("%s: got error code %q, want %q", tt.name, code, codeInvalidRequest)
+			}
+		}
+		followees, err := repo.GetFollowees(t.Context(), "user-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(followees, tt.wantFollowees) {
+			t.Fatalf("%s: user-1 follows %v, want %v", tt.name, followees, tt.wantFollowees)
+		}
+	}
+}
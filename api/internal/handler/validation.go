@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxBodyBytes      = 16 << 10
+	maxContentRunes   = 280
+	jsonContentType   = "application/json"
+	jsonTrailingError = "Request body must contain a single JSON object"
+)
+
+// decodeJSON strictly decodes a JSON request body into dst: the Content-Type
+// must be application/json, the body is capped at maxBodyBytes, must be valid
+// UTF-8 and unknown fields are rejected. The encoding is checked on the raw
+// bytes because the decoder silently replaces invalid sequences with U+FFFD.
+func decodeJSON(rw http.ResponseWriter, r *http.Request, dst any) *apiError {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != jsonContentType {
+		return &apiError{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    codeUnsupportedMediaType,
+			Message: "Content-Type must be " + jsonContentType,
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxBodyBytes))
+	if err != nil {
+		return decodeError(err)
+	}
+	if !utf8.Valid(body) {
+		return badRequest("", "Request body must be valid UTF-8")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err = decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return badRequest("", jsonTrailingError)
+	}
+	return nil
+}
+
+func decodeError(err error) *apiError {
+	var (
+		maxBytesErr  *http.MaxBytesError
+		syntaxErr    *json.SyntaxError
+		typeErr      *json.UnmarshalTypeError
+		unknownField = "json: unknown field "
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &apiError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    codePayloadTooLarge,
+			Message: fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit),
+		}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest("", "Request body is not valid JSON")
+	case errors.As(err, &typeErr):
+		return badRequest(typeErr.Field, fmt.Sprintf("Field must be of type %s", typeErr.Type))
+	case strings.HasPrefix(err.Error(), unknownField):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), unknownField), `"`)
+		return badRequest(field, "Unknown field")
+	case errors.Is(err, io.EOF):
+		return badRequest("", "Request body must not be empty")
+	default:
+		return badRequest("", "Invalid request body")
+	}
+}
+
+func validateContent(content string) *apiError {
+	if strings.TrimSpace(content) == "" {
+		return validationFailed("content", "Content must not be empty")
+	}
+	if n := utf8.RuneCountInString(content); n > maxContentRunes {
+		return validationFailed("content", fmt.Sprintf("Content must be at most %d characters, got %d", maxContentRunes, n))
+	}
+	return nil
+}
+
+func validateIdempotencyKey(key string) *apiError {
+	if len(key) > maxIdempotencyKeyLen {
+		return badRequest(idempotencyKeyHeader, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLen))
+	}
+	for _, c := range key {
+		if c < 0x21 || c > 0x7e {
+			return badRequest(idempotencyKeyHeader, "Idempotency-Key must contain only printable ASCII characters")
+		}
+	}
+	return nil
+}
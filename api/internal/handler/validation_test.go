@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		field       string
+	}{
+		{name: "valid", contentType: "application/json", body: `{"content":"hi"}`},
+		{name: "content type with charset", contentType: "application/json; charset=utf-8", body: `{"content":"hi"}`},
+		{name: "missing content type", body: `{"content":"hi"}`, status: http.StatusUnsupportedMediaType, code: codeUnsupportedMediaType},
+		{name: "wrong content type", contentType: "text/plain", body: `{"content":"hi"}`, status: http.StatusUnsupportedMediaType, code: codeUnsupportedMediaType},
+		{name: "empty body", contentType: "application/json", status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "malformed", contentType: "application/json", body: `{"content":`, status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "syntax error", contentType: "application/json", body: `{"content" "hi"}`, status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "wrong type", contentType: "application/json", body: `{"content":1}`, status: http.StatusBadRequest, code: codeInvalidRequest, field: "content"},
+		{name: "unknown field", contentType: "application/json", body: `{"content":"hi","author":"x"}`, status: http.StatusBadRequest, code: codeInvalidRequest, field: "author"},
+		{name: "trailing object", contentType: "application/json", body: `{"content":"hi"}{"content":"again"}`, status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "trailing garbage", contentType: "application/json", body: `{"content":"hi"} x`, status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "trailing whitespace", contentType: "application/json", body: "{\"content\":\"hi\"}\n"},
+		{name: "invalid utf-8", contentType: "application/json", body: "{\"content\":\"h\xffi\"}", status: http.StatusBadRequest, code: codeInvalidRequest},
+		{name: "oversized", contentType: "application/json", body: `{"content":"` + strings.Repeat("a", maxBodyBytes) + `"}`, status: http.StatusRequestEntityTooLarge, code: codePayloadTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			var request struct {
+				Content string `json:"content"`
+			}
+			apiErr := decodeJSON(rec, req, &request)
+			if tt.code == "" {
+				if apiErr != nil {
+					t.Fatalf("got %v, want no error", apiErr)
+				}
+				if request.Content != "hi" {
+					t.Fatalf("decoded content %q, want hi", request.Content)
+				}
+				return
+			}
+			if apiErr == nil {
+				t.Fatal("got no error")
+			}
+			if apiErr.Status != tt.status || apiErr.Code != tt.code || apiErr.Field != tt.field {
+				t.Fatalf("got %d %s field %q, want %d %s field %q", apiErr.Status, apiErr.Code, apiErr.Field, tt.status, tt.code, tt.field)
+			}
+		})
+	}
+}
+
+func TestWriteErrorEnvelope(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, badRequest("author", "Unknown field"))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("got Content-Type %q", got)
+	}
+	var body map[string]map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"code": codeInvalidRequest, "message": "Unknown field", "field": "author"}
+	if len(body) != 1 || len(body["error"]) != len(want) {
+		t.Fatalf("got %v, want only the error envelope %v", body, want)
+	}
+	for k, v := range want {
+		if body["error"][k] != v {
+			t.Fatalf("got %s %q, want %q", k, body["error"][k], v)
+		}
+	}
+
+	// The field is left out of errors about the whole request.
+	rec = httptest.NewRecorder()
+	writeError(rec, notFound("Message not found"))
+	if strings.Contains(rec.Body.String(), `"field"`) {
+		t.Fatalf("got %s, want no field", rec.Body.String())
+	}
+}
+
+func TestValidateContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{name: "text", content: "hello", valid: true},
+		{name: "empty", content: ""},
+		{name: "whitespace", content: " \n\t"},
+		{name: "at limit in runes", content: strings.Repeat("é", maxContentRunes), valid: true},
+		{name: "over limit", content: strings.Repeat("a", maxContentRunes+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := validateContent(tt.content)
+			if tt.valid {
+				if apiErr != nil {
+					t.Fatalf("got %v, want valid", apiErr)
+				}
+				return
+			}
+			if apiErr == nil || apiErr.Code != codeValidationFailed || apiErr.Field != "content" {
+				t.Fatalf("got %+v, want a validation error on content", apiErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package messaging
+
+// Kafka headers attached to events forwarded to retry and dead-letter topics,
+// and to events replayed from the dead-letter topic.
+const (
+	HeaderError           = "x-error"
+	HeaderAttempts        = "x-attempts"
+	HeaderRetryAt         = "x-retry-at"
+	HeaderSourceTopic     = "x-source-topic"
+	HeaderSourcePartition = "x-source-partition"
+	HeaderSourceOffset    = "x-source-offset"
+	HeaderFailedAt        = "x-failed-at"
+	HeaderReplayOf        = "x-replay-of"
+)
@@ -3,6 +3,8 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,24 +22,74 @@ type Event[T Eventable] struct {
 	data      T
 }
 
+// NewEventMessage wraps data in an event stamped with its event type and the
+// version DefaultRegistry writes that type with.
 func NewEventMessage[T Eventable](data T) *Event[T] {
+	kind := eventType(data)
 	return &Event[T]{
 		id:        uuid.New().String(),
-		eventType: "message",
+		eventType: kind,
 		timestamp: time.Now(),
-		version:   "1.0",
+		version:   DefaultRegistry.Version(kind),
 		data:      data,
 	}
 }
 
+// NewEventMessageWithID creates an event with a caller-chosen id, used as a
+// de-duplication key when the same event may be published more than once.
+func NewEventMessageWithID[T Eventable](id string, data T) *Event[T] {
+	event := NewEventMessage(data)
+	event.id = id
+	return event
+}
+
+// As returns event with its data typed as T, or false if the data is not a T.
+// It lets code reading several event types hand each to code expecting one.
+func As[T, U Eventable](event *Event[U]) (*Event[T], bool) {
+	data, ok := any(event.data).(T)
+	if !ok {
+		return nil, false
+	}
+	return &Event[T]{
+		id:        event.id,
+		eventType: event.eventType,
+		timestamp: event.timestamp,
+		version:   event.version,
+		data:      data,
+	}, true
+}
+
 func (e *Event[T]) Process(ctx context.Context, fn func(ctx context.Context, data T) error) error {
 	return fn(ctx, e.data)
 }
 
+func (e *Event[T]) ID() string {
+	return e.id
+}
+
+func (e *Event[T]) Type() string {
+	return e.eventType
+}
+
+func (e *Event[T]) Version() string {
+	return e.version
+}
+
+func (e *Event[T]) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func (e *Event[T]) Data() T {
+	return e.data
+}
+
 func (e *Event[T]) MarshalData() ([]byte, error) {
 	return json.Marshal(e.data)
 }
 
+// UnmarshalJSON decodes the data through DefaultRegistry, upcasting payloads
+// written with an old version. Event types the registry does not know are
+// decoded straight into T.
 func (e *Event[T]) UnmarshalJSON(data []byte) error {
 	type Payload struct {
 		ID        string          `json:"id"`
@@ -57,10 +109,24 @@ func (e *Event[T]) UnmarshalJSON(data []byte) error {
 	e.timestamp = decodedPayload.Timestamp
 	e.version = decodedPayload.Version
 
-	var dataValue T
-	if err := json.Unmarshal(decodedPayload.Data, &dataValue); err != nil {
+	decoded, version, err := DefaultRegistry.Decode(e.eventType, e.version, decodedPayload.Data)
+	if errors.Is(err, ErrUnknownEvent) {
+		var dataValue T
+		if err := json.Unmarshal(decodedPayload.Data, &dataValue); err != nil {
+			return err
+		}
+		e.data = dataValue
+		return nil
+	}
+	if err != nil {
 		return err
 	}
+
+	dataValue, ok := decoded.(T)
+	if !ok {
+		return fmt.Errorf("%w: %s event holds %T, not %T", ErrUnexpectedEvent, e.eventType, decoded, dataValue)
+	}
+	e.version = version
 	e.data = dataValue
 
 	return nil
@@ -88,3 +154,13 @@ func (e *Event[T]) MarshalJSON() ([]byte, error) {
 	}
 	return json.Marshal(encodedPayload)
 }
+
+// PartitionKey is the default partition key of an event: the author for data
+// with a UserID method, so each user's events stay in order, and the event id
+// otherwise.
+func PartitionKey(eventID string, data any) string {
+	if authored, ok := data.(interface{ UserID() string }); ok {
+		return authored.UserID()
+	}
+	return eventID
+}
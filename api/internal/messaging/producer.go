@@ -3,28 +3,184 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"feed-api/internal/transport"
+	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DeliveryMode controls when Publish returns and what happens to events the
+// broker fails to accept.
+type DeliveryMode string
+
+const (
+	// DeliverSync returns once all in-sync replicas have the event.
+	DeliverSync DeliveryMode = "sync"
+	// DeliverAsync hands the event to the writer and returns. Nothing waits for
+	// the broker, and lost events are only counted.
+	DeliverAsync DeliveryMode = "async"
+	// DeliverAsyncRetry returns immediately. Writes are acknowledged by all
+	// in-sync replicas in the background; failed events are retried from a
+	// bounded queue and reported to the failure handler once given up.
+	// Retried events may be published after later events with the same key.
+	DeliverAsyncRetry DeliveryMode = "async-retry"
+)
 
-	"github.com/segmentio/kafka-go"
+const (
+	DefaultRetryQueueSize   = 1000
+	DefaultRetryMaxAttempts = 5
+
+	retryInitialBackoff = 100 * time.Millisecond
+	retryMaxBackoff     = 5 * time.Second
 )
 
-type KafkaProducer[T Eventable] struct {
-	writer *kafka.Writer
+func ParseDeliveryMode(raw string) (DeliveryMode, error) {
+	switch mode := DeliveryMode(raw); mode {
+	case DeliverSync, DeliverAsync, DeliverAsyncRetry:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown delivery mode %q", raw)
+	}
+}
+
+// Durable reports whether a successful Publish means the broker has the event.
+func (m DeliveryMode) Durable() bool {
+	return m == DeliverSync
+}
+
+// KeyFunc returns the partition key of data. Events with the same key land on
+// the same partition and are consumed in the order they were published.
+type KeyFunc[T Eventable] func(data T) string
+
+type ProducerOption[T Eventable] func(*Producer[T])
+
+// WithPartitionKey sets how events are keyed. By default events whose data has
+// a UserID method are keyed by user, and other events by their event id.
+func WithPartitionKey[T Eventable](fn KeyFunc[T]) ProducerOption[T] {
+	return func(p *Producer[T]) {
+		p.key = fn
+	}
+}
+
+// WithDeliveryMode sets the delivery mode. The default is DeliverSync.
+func WithDeliveryMode[T Eventable](mode DeliveryMode) ProducerOption[T] {
+	return func(p *Producer[T]) {
+		p.mode = mode
+	}
 }
 
-func NewProducer[T Eventable](brokers []string, topic string) *KafkaProducer[T] {
-	writer := &kafka.Writer{
-		Addr:     kafka.TCP(brokers...),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
-		Async:    true,
+// WithRetryQueue bounds the DeliverAsyncRetry queue and the attempts made for
+// each queued event.
+func WithRetryQueue[T Eventable](size, maxAttempts int) ProducerOption[T] {
+	return func(p *Producer[T]) {
+		p.retryQueueSize = size
+		p.retryMaxAttempts = maxAttempts
+	}
+}
+
+// WithFailureHandler is called in DeliverAsyncRetry mode for every event that
+// could not be delivered.
+func WithFailureHandler[T Eventable](fn func(data T, err error)) ProducerOption[T] {
+	return func(p *Producer[T]) {
+		p.onFailure = fn
+	}
+}
+
+// DeliveryStats counts the events a producer handled.
+type DeliveryStats struct {
+	Published uint64 `json:"published"`
+	Delivered uint64 `json:"delivered"`
+	Failed    uint64 `json:"failed"`
+	Retried   uint64 `json:"retried"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+type Broker interface {
+	NewPublisher(config transport.PublisherConfig) transport.Publisher
+}
+
+// Producer publishes events to a topic, partitioned by key.
+type Producer[T Eventable] struct {
+	publisher transport.Publisher
+	topic     string
+	key       KeyFunc[T]
+	mode      DeliveryMode
+
+	retryQueueSize   int
+	retryMaxAttempts int
+	retryPublisher   transport.Publisher
+	retries          chan transport.Message
+	retryWG          sync.WaitGroup
+	stopRetrying     context.CancelFunc
+	onFailure        func(data T, err error)
+
+	published atomic.Uint64
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	retried   atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+func NewProducer[T Eventable](broker Broker, topic string, options ...ProducerOption[T]) *Producer[T] {
+	p := &Producer[T]{
+		topic:            topic,
+		mode:             DeliverSync,
+		retryQueueSize:   DefaultRetryQueueSize,
+		retryMaxAttempts: DefaultRetryMaxAttempts,
+	}
+	for _, option := range options {
+		option(p)
+	}
+
+	config := transport.PublisherConfig{
+		Partitioning: transport.PartitionByKey,
+		Completion:   p.complete,
 	}
-	return &KafkaProducer[T]{
-		writer: writer,
+	switch p.mode {
+	case DeliverAsync:
+		config.Async = true
+		config.Acks = transport.AckNone
+	case DeliverAsyncRetry:
+		config.Async = true
+		p.retryPublisher = broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey})
+		p.retries = make(chan transport.Message, max(p.retryQueueSize, 1))
+		ctx, cancel := context.WithCancel(context.Background())
+		p.stopRetrying = cancel
+		p.retryWG.Add(1)
+		go p.retryLoop(ctx)
+	}
+	p.publisher = broker.NewPublisher(config)
+	return p
+}
+
+// Durable reports whether a successful Publish means the broker has the event.
+func (p *Producer[T]) Durable() bool {
+	return p.mode.Durable()
+}
+
+func (p *Producer[T]) Stats() DeliveryStats {
+	return DeliveryStats{
+		Published: p.published.Load(),
+		Delivered: p.delivered.Load(),
+		Failed:    p.failed.Load(),
+		Retried:   p.retried.Load(),
+		Dropped:   p.dropped.Load(),
 	}
 }
 
-func (p *KafkaProducer[T]) Publish(ctx context.Context, data T) error {
+func (p *Producer[T]) Publish(ctx context.Context, data T) error {
+	return p.publish(ctx, data, nil)
+}
+
+// PublishReplay publishes data as a fresh event, marked with a header naming
+// the dead-lettered event it replays.
+func (p *Producer[T]) PublishReplay(ctx context.Context, data T, replayOf string) error {
+	return p.publish(ctx, data, []transport.Header{{Key: HeaderReplayOf, Value: []byte(replayOf)}})
+}
+
+func (p *Producer[T]) publish(ctx context.Context, data T, headers []transport.Header) error {
 	event := NewEventMessage(data)
 
 	encodedEvent, err := json.Marshal(event)
@@ -32,21 +188,114 @@ func (p *KafkaProducer[T]) Publish(ctx context.Context, data T) error {
 		return err
 	}
 
-	msg := kafka.Message{
-		Key:   []byte(event.id),
-		Value: encodedEvent,
+	msg := transport.Message{
+		Topic:   p.topic,
+		Key:     []byte(p.partitionKey(event)),
+		Value:   encodedEvent,
+		Headers: headers,
 	}
 
-	if err = p.writer.WriteMessages(ctx, msg); err != nil {
+	if err = p.publisher.Publish(ctx, msg); err != nil {
 		log.Println("Failed to publish message:", err)
 		return err
 	}
 
+	p.published.Add(1)
 	log.Println("Successfully published message")
 	return nil
 }
 
-func (p *KafkaProducer[T]) Close() error {
-	log.Println("Closing kafka writer")
-	return p.writer.Close()
+func (p *Producer[T]) partitionKey(event *Event[T]) string {
+	if p.key != nil {
+		return p.key(event.data)
+	}
+	return PartitionKey(event.id, event.data)
+}
+
+// complete records the result of a write. In DeliverAsyncRetry mode failed
+// events are queued for retry, or given up when the queue is full.
+func (p *Producer[T]) complete(msgs []transport.Message, err error) {
+	if err == nil {
+		p.delivered.Add(uint64(len(msgs)))
+		return
+	}
+	p.failed.Add(uint64(len(msgs)))
+	log.Printf("Failed to deliver %d message(s): %v", len(msgs), err)
+	if p.mode != DeliverAsyncRetry {
+		return
+	}
+	for _, msg := range msgs {
+		select {
+		case p.retries <- msg:
+		default:
+			p.giveUp(msg, fmt.Errorf("retry queue full: %w", err))
+		}
+	}
+}
+
+// retryLoop republishes queued events synchronously with exponential backoff.
+// Once ctx is done, remaining events get one last attempt without waiting.
+func (p *Producer[T]) retryLoop(ctx context.Context) {
+	defer p.retryWG.Done()
+
+	for msg := range p.retries {
+		var err error
+		backoff := retryInitialBackoff
+		for attempt := 1; attempt <= p.retryMaxAttempts; attempt++ {
+			p.retried.Add(1)
+			if err = p.retryPublisher.Publish(context.Background(), msg); err == nil {
+				p.delivered.Add(1)
+				break
+			}
+			if attempt == p.retryMaxAttempts || sleep(ctx, backoff) != nil {
+				break
+			}
+			backoff = min(2*backoff, retryMaxBackoff)
+		}
+		if err != nil {
+			p.giveUp(msg, err)
+		}
+	}
+}
+
+func (p *Producer[T]) giveUp(msg transport.Message, err error) {
+	p.dropped.Add(1)
+	log.Println("Dropping undeliverable message:", err)
+	if p.onFailure == nil {
+		return
+	}
+	var event Event[T]
+	if decodeErr := json.Unmarshal(msg.Value, &event); decodeErr != nil {
+		log.Println("Error decoding undeliverable message:", decodeErr)
+		return
+	}
+	p.onFailure(event.data, err)
+}
+
+// Close flushes pending writes. In DeliverAsyncRetry mode it then drains the
+// retry queue before returning.
+func (p *Producer[T]) Close() error {
+	log.Println("Closing producer")
+	err := p.publisher.Close()
+	if p.retries != nil {
+		p.stopRetrying()
+		close(p.retries)
+		p.retryWG.Wait()
+		if retryErr := p.retryPublisher.Close(); err == nil {
+			err = retryErr
+		}
+	}
+	return err
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,468 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feed-api/internal/transport"
+	"feed-api/internal/transport/memory"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errBrokerDown = errors.New("broker down")
+
+// flakyBroker wraps the memory transport with publishers that can be made to
+// fail. Async publishers only report failures to their Completion, like the
+// kafka driver.
+type flakyBroker struct {
+	*memory.Transport
+
+	mu sync.Mutex
+	// failAsync fails every write of async publishers.
+	failAsync bool
+	// syncFailures is the number of writes of sync publishers left to fail,
+	// or -1 to fail them all.
+	syncFailures int
+	// gate, if set, holds writes of sync publishers until it is closed.
+	// entered receives a value as each held write arrives.
+	gate    chan struct{}
+	entered chan struct{}
+}
+
+func newFlakyBroker() *flakyBroker {
+	return &flakyBroker{Transport: memory.New(1)}
+}
+
+func (b *flakyBroker) NewPublisher(config transport.PublisherConfig) transport.Publisher {
+	return &flakyPublisher{
+		broker:    b,
+		publisher: b.Transport.NewPublisher(transport.PublisherConfig{Partitioning: config.Partitioning}),
+		config:    config,
+	}
+}
+
+func (b *flakyBroker) fail(async bool) error {
+	b.mu.Lock()
+	gate, entered := b.gate, b.entered
+	b.mu.Unlock()
+	if !async && gate != nil {
+		entered <- struct{}{}
+		<-gate
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if async {
+		if b.failAsync {
+			return errBrokerDown
+		}
+		return nil
+	}
+	if b.syncFailures != 0 {
+		if b.syncFailures > 0 {
+			b.syncFailures--
+		}
+		return errBrokerDown
+	}
+	return nil
+}
+
+func (b *flakyBroker) published(t *testing.T, topic string) []string {
+	t.Helper()
+	var authors []string
+	err := b.Read(context.Background(), topic, 0, 0, 1<<20, func(msg transport.Message) bool {
+		authors = append(authors, string(msg.Key))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authors
+}
+
+type flakyPublisher struct {
+	broker    *flakyBroker
+	publisher transport.Publisher
+	config    transport.PublisherConfig
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, msgs ...transport.Message) error {
+	err := p.broker.fail(p.config.Async)
+	if err == nil {
+		err = p.publisher.Publish(ctx, msgs...)
+	}
+	if p.config.Completion != nil {
+		p.config.Completion(msgs, err)
+	}
+	if p.config.Async {
+		return nil
+	}
+	return err
+}
+
+func (p *flakyPublisher) Close() error {
+	return p.publisher.Close()
+}
+
+// testAuthored is keyed by its author.
+type testAuthored struct {
+	testPost
+}
+
+func (a *testAuthored) UserID() string {
+	return a.Author
+}
+
+func newAuthored(author string) *testAuthored {
+	return &testAuthored{testPost{Author: author, Text: "hello"}}
+}
+
+// failures collects the events given up by a producer.
+type failures struct {
+	mu      sync.Mutex
+	authors []string
+	errs    []error
+}
+
+func (f *failures) handle(data *testAuthored, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.authors = append(f.authors, data.Author)
+	f.errs = append(f.errs, err)
+}
+
+func assertStats(t *testing.T, got, want DeliveryStats) {
+	t.Helper()
+	if got != want {
+		t.Fatalf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestProducerDeliverSync(t *testing.T) {
+	broker := newFlakyBroker()
+	broker.syncFailures = 1
+	p := NewProducer[*testAuthored](broker, "events")
+	defer p.Close()
+
+	if !p.Durable() {
+		t.Fatal("sync producer is not durable")
+	}
+	if err := p.Publish(context.Background(), newAuthored("a")); !errors.Is(err, errBrokerDown) {
+		t.Fatalf("got %v, want the broker error", err)
+	}
+	if err := p.Publish(context.Background(), newAuthored("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	assertStats(t, p.Stats(), DeliveryStats{Published: 1, Delivered: 1, Failed: 1})
+	if got := broker.published(t, "events"); !slices.Equal(got, []string{"b"}) {
+		t.Fatalf("published %v, want b keyed by its author", got)
+	}
+}
+
+func TestProducerDeliverAsyncOnlyCountsFailures(t *testing.T) {
+	broker := newFlakyBroker()
+	broker.failAsync = true
+	var failed failures
+	p := NewProducer(broker, "events",
+		WithDeliveryMode[*testAuthored](DeliverAsync),
+		WithFailureHandler(failed.handle),
+	)
+
+	if p.Durable() {
+		t.Fatal("async producer is durable")
+	}
+	if err := p.Publish(context.Background(), newAuthored("a")); err != nil {
+		t.Fatalf("got %v, want failures to stay in the background", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertStats(t, p.Stats(), DeliveryStats{Published: 1, Failed: 1})
+	if len(failed.authors) != 0 {
+		t.Fatalf("reported %v to the failure handler, want nothing", failed.authors)
+	}
+	if got := broker.published(t, "events"); len(got) != 0 {
+		t.Fatalf("published %v, want nothing", got)
+	}
+}
+
+func TestProducerRetriesFailedEventsInOrder(t *testing.T) {
+	broker := newFlakyBroker()
+	broker.failAsync = true
+	// Fail the first retry; the event is retried after a backoff and the
+	// events queued behind it wait for it.
+	broker.syncFailures = 1
+	var failed failures
+	p := NewProducer(broker, "events",
+		WithDeliveryMode[*testAuthored](DeliverAsyncRetry),
+		WithFailureHandler(failed.handle),
+	)
+
+	for _, author := range []string{"a", "b", "c"} {
+		if err := p.Publish(context.Background(), newAuthored(author)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.After(2 * time.Second)
+	for p.Stats().Delivered < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("delivered %d of 3 events", p.Stats().Delivered)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertStats(t, p.Stats(), DeliveryStats{Published: 3, Delivered: 3, Failed: 3, Retried: 4})
+	if got := broker.published(t, "events"); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Fatalf("published %v, want the queue order", got)
+	}
+	if len(failed.authors) != 0 {
+		t.Fatalf("gave up %v, want every event delivered", failed.authors)
+	}
+}
+
+func TestProducerGivesUpAfterMaxAttempts(t *testing.T) {
+	broker := newFlakyBroker()
+	broker.failAsync = true
+	broker.syncFailures = -1
+	var failed failures
+	p := NewProducer(broker, "events",
+		WithDeliveryMode[*testAuthored](DeliverAsyncRetry),
+		WithRetryQueue[*testAuthored](10, 2),
+		WithFailureHandler(failed.handle),
+	)
+
+	if err := p.Publish(context.Background(), newAuthored("a")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(2 * time.Second)
+	for p.Stats().Dropped == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("event was never given up")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertStats(t, p.Stats(), DeliveryStats{Published: 1, Failed: 1, Retried: 2, Dropped: 1})
+	if !slices.Equal(failed.authors, []string{"a"}) || !errors.Is(failed.errs[0], errBrokerDown) {
+		t.Fatalf("gave up %v with %v, want a with the broker error", failed.authors, failed.errs)
+	}
+}
+
+func TestProducerGivesUpWhenRetryQueueIsFull(t *testing.T) {
+	broker := newFlakyBroker()
+	broker.failAsync = true
+	broker.gate = make(chan struct{})
+	broker.entered = make(chan struct{}, 1)
+	var failed failures
+	p := NewProducer(broker, "events",
+		WithDeliveryMode[*testAuthored](DeliverAsyncRetry),
+		WithRetryQueue[*testAuthored](1, 1),
+		WithFailureHandler(failed.handle),
+	)
+
+	// a is held in its retry, b waits in the queue and c finds it full.
+	if err := p.Publish(context.Background(), newAuthored("a")); err != nil {
+		t.Fatal(err)
+	}
+	<-broker.entered
+	for _, author := range []string{"b", "c"} {
+		if err := p.Publish(context.Background(), newAuthored(author)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(failed.authors, []string{"c"}) {
+		t.Fatalf("gave up %v, want c", failed.authors)
+	}
+	if !errors.Is(failed.errs[0], errBrokerDown) || !strings.Contains(failed.errs[0].Error(), "retry queue full") {
+		t.Fatalf("gave up c with %v, want the full queue and the broker error", failed.errs[0])
+	}
+
+	close(broker.gate)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertStats(t, p.Stats(), DeliveryStats{Published: 3, Delivered: 2, Failed: 3, Retried: 2, Dropped: 1})
+	if got := broker.published(t, "events"); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("published %v, want a and b", got)
+	}
+}
+
+func TestProducerCloseDrainsRetryQueueWithoutBackoff(t *testing.T) {
+	broker := newFlakyBroker()
+	broker.failAsync = true
+	broker.syncFailures = -1
+	var failed failures
+	p := NewProducer(broker, "events",
+		WithDeliveryMode[*testAuthored](DeliverAsyncRetry),
+		WithRetryQueue[*testAuthored](10, 5),
+		WithFailureHandler(failed.handle),
+	)
+
+	var authors []string
+	for n := range 3 {
+		authors = append(authors, fmt.Sprintf("user-%d", n))
+		if err := p.Publish(context.Background(), newAuthored(authors[n])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Five attempts with backoff would take seconds per event; closing
+	// gives each queued event one last attempt and returns.
+	start := time.Now()
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("closing took %v, want the backoff to be cut short", elapsed)
+	}
+	if !slices.Equal(failed.authors, authors) {
+		t.Fatalf("gave up %v, want every queued event %v", failed.authors, authors)
+	}
+	if stats := p.Stats(); stats.Dropped != 3 || stats.Delivered != 0 {
+		t.Fatalf("got stats %+v, want all 3 dropped", stats)
+	}
+}
+
+func TestProducerCloseDeliversQueuedEvents(t *testing.T) {
+	broker := newFlakyBroker()
+	broker.failAsync = true
+	p := NewProducer[*testAuthored](broker, "events", WithDeliveryMode[*testAuthored](DeliverAsyncRetry))
+
+	for _, author := range []string{"a", "b", "c"} {
+		if err := p.Publish(context.Background(), newAuthored(author)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := p.Stats(); stats.Delivered != 3 || stats.Dropped != 0 {
+		t.Fatalf("got stats %+v, want all 3 delivered", stats)
+	}
+	if got := broker.published(t, "events"); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Fatalf("published %v, want the queue order", got)
+	}
+}
+
+// partitioned is an event read back from a partition of the memory transport.
+type partitioned struct {
+	partition int
+	key       string
+	text      string
+}
+
+// readPartitions returns the events of every partition of topic, each
+// partition in offset order.
+func readPartitions(t *testing.T, broker *memory.Transport, topic string, partitions int) []partitioned {
+	t.Helper()
+	var events []partitioned
+	for p := range partitions {
+		err := broker.Read(context.Background(), topic, p, 0, 1<<20, func(msg transport.Message) bool {
+			var event struct {
+				Data testPost `json:"data"`
+			}
+			if err := json.Unmarshal(msg.Value, &event); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, partitioned{partition: p, key: string(msg.Key), text: event.Data.Text})
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return events
+}
+
+func TestProducerKeepsEventsWithTheSameKeyOnOnePartitionInOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ProducerOption[*testAuthored]
+		// key is the partition key expected for an event by author.
+		key func(author string) string
+	}{
+		{"default key", nil, func(author string) string { return author }},
+		{
+			"custom key",
+			[]ProducerOption[*testAuthored]{WithPartitionKey(func(data *testAuthored) string { return "team-" + data.Author[:1] })},
+			func(author string) string { return "team-" + author[:1] },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := memory.New(4)
+			p := NewProducer(broker, "events", tt.options...)
+			defer p.Close()
+
+			want := make(map[string][]string)
+			for i := range 10 {
+				for _, author := range []string{"alice", "bob", "amir", "carol"} {
+					text := fmt.Sprintf("%s-%d", author, i)
+					if err := p.Publish(context.Background(), &testAuthored{testPost{Author: author, Text: text}}); err != nil {
+						t.Fatal(err)
+					}
+					want[tt.key(author)] = append(want[tt.key(author)], text)
+				}
+			}
+
+			got := make(map[string][]string)
+			partitions := make(map[string]int)
+			for _, event := range readPartitions(t, broker, "events", 4) {
+				if prev, ok := partitions[event.key]; ok && prev != event.partition {
+					t.Fatalf("key %s in partitions %d and %d", event.key, prev, event.partition)
+				}
+				partitions[event.key] = event.partition
+				got[event.key] = append(got[event.key], event.text)
+			}
+			for key, texts := range want {
+				if !slices.Equal(got[key], texts) {
+					t.Fatalf("key %s: got %v, want %v in publish order", key, got[key], texts)
+				}
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d keys, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestProducerPublishesWithoutAKey(t *testing.T) {
+	broker := memory.New(4)
+	p := NewProducer(broker, "events", WithPartitionKey(func(*testAuthored) string { return "" }))
+	defer p.Close()
+
+	for i := range 8 {
+		if err := p.Publish(context.Background(), &testAuthored{testPost{Author: "alice", Text: fmt.Sprint(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events := readPartitions(t, broker, "events", 4)
+	used := make(map[int]bool)
+	for _, event := range events {
+		if event.key != "" {
+			t.Fatalf("got key %q, want none", event.key)
+		}
+		used[event.partition] = true
+	}
+	// Unkeyed events are spread over the partitions.
+	if len(events) != 8 || len(used) != 4 {
+		t.Fatalf("got %d events over %d partitions, want 8 over 4", len(events), len(used))
+	}
+	assertStats(t, p.Stats(), DeliveryStats{Published: 8, Delivered: 8})
+}
@@ -0,0 +1,139 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Event type and version of data that does not name its own type, and of
+// types registered without a version.
+const (
+	DefaultEventType    = "message"
+	DefaultEventVersion = "1.0"
+)
+
+var (
+	// ErrUnknownEvent is returned for an event type and version the registry
+	// can neither decode nor upcast.
+	ErrUnknownEvent = errors.New("messaging: unknown event")
+	// ErrUnexpectedEvent is returned when an event decodes to data of another
+	// type than the Event it is decoded into.
+	ErrUnexpectedEvent = errors.New("messaging: unexpected event type")
+)
+
+// Typed is implemented by event data that names its event type. Other data is
+// published as DefaultEventType.
+type Typed interface {
+	EventType() string
+}
+
+// Decoder decodes the data of one event type and version.
+type Decoder func(data json.RawMessage) (Eventable, error)
+
+// Upcaster rewrites the data of an event written with an old version into the
+// shape of a newer one.
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+type schema struct {
+	eventType string
+	version   string
+}
+
+type upcaster struct {
+	to string
+	fn Upcaster
+}
+
+// Registry maps event types and versions to decoders. Old versions that have
+// no decoder of their own are upcast step by step until one has.
+type Registry struct {
+	mu        sync.RWMutex
+	current   map[string]string
+	decoders  map[schema]Decoder
+	upcasters map[schema]upcaster
+}
+
+// DefaultRegistry is used by Event to stamp and decode events.
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		current:   make(map[string]string),
+		decoders:  make(map[schema]Decoder),
+		upcasters: make(map[schema]upcaster),
+	}
+}
+
+// Register decodes eventType at version into T. The last version registered
+// for a type is the one new events are written with.
+func Register[T Eventable](r *Registry, eventType, version string) {
+	r.Register(eventType, version, func(data json.RawMessage) (Eventable, error) {
+		var value T
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	})
+}
+
+func (r *Registry) Register(eventType, version string, decoder Decoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders[schema{eventType, version}] = decoder
+	r.current[eventType] = version
+}
+
+// RegisterUpcaster makes events of eventType written with version from
+// readable as version to.
+func (r *Registry) RegisterUpcaster(eventType, from, to string, fn Upcaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.upcasters[schema{eventType, from}] = upcaster{to: to, fn: fn}
+}
+
+// Version returns the version new events of eventType are written with.
+func (r *Registry) Version(eventType string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if version, ok := r.current[eventType]; ok {
+		return version
+	}
+	return DefaultEventVersion
+}
+
+// Decode decodes the data of an event, upcasting it first if its version has
+// no decoder. It returns the version the data was decoded as.
+func (r *Registry) Decode(eventType, version string, data json.RawMessage) (Eventable, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for {
+		key := schema{eventType, version}
+		if decoder, ok := r.decoders[key]; ok {
+			value, err := decoder(data)
+			return value, version, err
+		}
+		up, ok := r.upcasters[key]
+		if !ok || seen[version] {
+			return nil, version, fmt.Errorf("%w: %s version %s", ErrUnknownEvent, eventType, version)
+		}
+		seen[version] = true
+
+		upcast, err := up.fn(data)
+		if err != nil {
+			return nil, version, fmt.Errorf("upcast %s from %s to %s: %w", eventType, version, up.to, err)
+		}
+		data, version = upcast, up.to
+	}
+}
+
+// eventType returns the event type data is published as.
+func eventType(data any) string {
+	if typed, ok := data.(Typed); ok {
+		return typed.EventType()
+	}
+	return DefaultEventType
+}
@@ -0,0 +1,91 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type testPost struct {
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+func (p *testPost) MarshalJSON() ([]byte, error) {
+	type plain testPost
+	return json.Marshal((*plain)(p))
+}
+
+type testLike struct {
+	Post string `json:"post"`
+}
+
+func (l *testLike) MarshalJSON() ([]byte, error) {
+	type plain testLike
+	return json.Marshal((*plain)(l))
+}
+
+func TestRegistryUpcastsOldVersions(t *testing.T) {
+	r := NewRegistry()
+	Register[*testPost](r, "post", "3")
+	// Version 1 called the author "user"; version 2 called the text "body".
+	r.RegisterUpcaster("post", "1", "2", func(data json.RawMessage) (json.RawMessage, error) {
+		var v1 struct {
+			User string `json:"user"`
+			Body string `json:"body"`
+		}
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]string{"author": v1.User, "body": v1.Body})
+	})
+	r.RegisterUpcaster("post", "2", "3", func(data json.RawMessage) (json.RawMessage, error) {
+		var v2 struct {
+			Author string `json:"author"`
+			Body   string `json:"body"`
+		}
+		if err := json.Unmarshal(data, &v2); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]string{"author": v2.Author, "text": v2.Body})
+	})
+
+	decoded, version, err := r.Decode("post", "1", json.RawMessage(`{"user":"user-1","body":"hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, ok := decoded.(*testPost)
+	if !ok || post.Author != "user-1" || post.Text != "hello" || version != "3" {
+		t.Fatalf("got %#v at version %s, want user-1/hello at version 3", decoded, version)
+	}
+
+	if _, _, err = r.Decode("post", "0", json.RawMessage(`{}`)); !errors.Is(err, ErrUnknownEvent) {
+		t.Fatalf("decoding an unregistered version: got %v, want ErrUnknownEvent", err)
+	}
+}
+
+func TestEventRejectsOtherEventTypes(t *testing.T) {
+	previous := DefaultRegistry
+	DefaultRegistry = NewRegistry()
+	defer func() { DefaultRegistry = previous }()
+	Register[*testPost](DefaultRegistry, "post", "1")
+	Register[*testLike](DefaultRegistry, "like", "1")
+
+	raw, err := json.Marshal(&Event[*testLike]{id: "1", eventType: "like", version: "1", data: &testLike{Post: "p"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var post Event[*testPost]
+	if err = json.Unmarshal(raw, &post); !errors.Is(err, ErrUnexpectedEvent) {
+		t.Fatalf("decoding a like as a post: got %v, want ErrUnexpectedEvent", err)
+	}
+
+	var event Event[Eventable]
+	if err = json.Unmarshal(raw, &event); err != nil {
+		t.Fatal(err)
+	}
+	if like, ok := event.Data().(*testLike); !ok || like.Post != "p" || event.Type() != "like" {
+		t.Fatalf("got %s event %#v, want the like", event.Type(), event.Data())
+	}
+}
@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"context"
+	"feed-api/internal/transport"
+	"log"
+)
+
+// TransportPublisher writes records to the broker. The underlying publisher
+// must be synchronous, so a record is only marked as sent once the broker has
+// it.
+type TransportPublisher struct {
+	publisher transport.Publisher
+}
+
+func NewTransportPublisher(publisher transport.Publisher) *TransportPublisher {
+	return &TransportPublisher{
+		publisher: publisher,
+	}
+}
+
+func (p *TransportPublisher) PublishRecords(ctx context.Context, records []Record) error {
+	messages := make([]transport.Message, 0, len(records))
+	for _, record := range records {
+		key := record.Key
+		if key == "" {
+			key = record.ID
+		}
+		messages = append(messages, transport.Message{
+			Topic: record.Topic,
+			Key:   []byte(key),
+			Value: record.Payload,
+		})
+	}
+	return p.publisher.Publish(ctx, messages...)
+}
+
+func (p *TransportPublisher) Close() error {
+	log.Println("Closing outbox publisher")
+	return p.publisher.Close()
+}
@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	DefaultBatchSize    = 100
+	DefaultPollInterval = 500 * time.Millisecond
+)
+
+// Record is an encoded event written to the outbox in the same transaction as
+// the change it describes. ID is the event's de-duplication key and Key its
+// partition key; records without a Key are partitioned by ID.
+type Record struct {
+	ID        string
+	Topic     string
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// Store hands pending records to a relay. RelayPendingOutbox passes up to
+// limit records, oldest first, to publish and marks them published if it
+// returns nil. While one relay holds a batch, relays of other instances get no
+// records, so each record is published by one relay and in order.
+type Store interface {
+	RelayPendingOutbox(ctx context.Context, limit int, publish func(records []Record) error) (int, error)
+}
+
+type Publisher interface {
+	PublishRecords(ctx context.Context, records []Record) error
+	Close() error
+}
+
+// Relay publishes pending outbox records and marks them as sent. A crash
+// between publishing and marking republishes the batch, so delivery is
+// at-least-once; consumers de-duplicate on the record id, which is also the
+// event id. Records are published in the order they were written, also when
+// every instance runs a relay: the Store hands a batch to one relay at a time.
+type Relay struct {
+	store        Store
+	publisher    Publisher
+	batchSize    int
+	pollInterval time.Duration
+}
+
+func NewRelay(store Store, publisher Publisher, batchSize int, pollInterval time.Duration) *Relay {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	return &Relay{
+		store:        store,
+		publisher:    publisher,
+		batchSize:    batchSize,
+		pollInterval: pollInterval,
+	}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	log.Println("Outbox relay started")
+	defer func() {
+		if err := r.publisher.Close(); err != nil {
+			log.Println("Error closing outbox publisher:", err)
+		}
+	}()
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches back to back; wait for the next tick otherwise.
+		n, err := r.relayBatch(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Println("Error relaying outbox:", err)
+		}
+		if err == nil && n == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Println("Outbox relay stopped")
+			return
+		}
+	}
+}
+
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	return r.store.RelayPendingOutbox(ctx, r.batchSize, func(records []Record) error {
+		return r.publisher.PublishRecords(ctx, records)
+	})
+}
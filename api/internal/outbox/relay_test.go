@@ -0,0 +1,280 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"feed-api/internal/transport"
+	"feed-api/internal/transport/memory"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+// fakeStore keeps the outbox in memory, in the order records were written.
+// Like the database, it hands out one batch at a time.
+type fakeStore struct {
+	claim     sync.Mutex
+	mu        sync.Mutex
+	pending   []Record
+	published []string
+	markErr   error
+}
+
+func (s *fakeStore) RelayPendingOutbox(ctx context.Context, limit int, publish func(records []Record) error) (int, error) {
+	if !s.claim.TryLock() {
+		return 0, nil
+	}
+	defer s.claim.Unlock()
+
+	s.mu.Lock()
+	records := slices.Clone(s.pending[:min(limit, len(s.pending))])
+	s.mu.Unlock()
+	if len(records) == 0 {
+		return 0, nil
+	}
+	if err := publish(records); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.markErr != nil {
+		return 0, s.markErr
+	}
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	s.pending = slices.DeleteFunc(s.pending, func(record Record) bool {
+		return slices.Contains(ids, record.ID)
+	})
+	s.published = append(s.published, ids...)
+	return len(records), nil
+}
+
+func (s *fakeStore) pendingCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// failingPublisher fails while err is set.
+type failingPublisher struct {
+	transport.Publisher
+	mu  sync.Mutex
+	err error
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, msgs ...transport.Message) error {
+	p.mu.Lock()
+	err := p.err
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return p.Publisher.Publish(ctx, msgs...)
+}
+
+func newTestRecords(n int) []Record {
+	records := make([]Record, 0, n)
+	for i := range n {
+		records = append(records, Record{
+			ID:      fmt.Sprintf("event-%d", i),
+			Topic:   "events-processed",
+			Key:     "author",
+			Payload: fmt.Appendf(nil, `{"n":%d}`, i),
+		})
+	}
+	return records
+}
+
+// topicValues returns the payloads on the single partition of topic.
+func topicValues(t *testing.T, broker *memory.Transport, topic string) []string {
+	t.Helper()
+	var values []string
+	err := broker.Read(context.Background(), topic, 0, 0, 1<<20, func(msg transport.Message) bool {
+		values = append(values, string(msg.Value))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func newTestRelay(store Store, batchSize int) (*Relay, *memory.Transport, *failingPublisher) {
+	broker := memory.New(1)
+	publisher := &failingPublisher{Publisher: broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey})}
+	return NewRelay(store, NewTransportPublisher(publisher), batchSize, time.Millisecond), broker, publisher
+}
+
+func TestRelayPublishesPendingRecordsInOrder(t *testing.T) {
+	records := newTestRecords(7)
+	store := &fakeStore{pending: slices.Clone(records)}
+	relay, broker, _ := newTestRelay(store, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.After(time.Second)
+	for store.pendingCount() > 0 {
+		select {
+		case <-deadline:
+			t.Fatalf("%d records still pending", store.pendingCount())
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	// Records written later are picked up on the next poll.
+	late := Record{ID: "late", Topic: "events-processed", Payload: []byte(`{"late":true}`)}
+	store.mu.Lock()
+	store.pending = append(store.pending, late)
+	store.mu.Unlock()
+	for store.pendingCount() > 0 {
+		select {
+		case <-deadline:
+			t.Fatal("late record still pending")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	var want []string
+	for _, record := range append(records, late) {
+		want = append(want, string(record.Payload))
+	}
+	if got := topicValues(t, broker, "events-processed"); !slices.Equal(got, want) {
+		t.Fatalf("published %v, want %v", got, want)
+	}
+}
+
+func TestConcurrentRelaysPublishEachRecordOnce(t *testing.T) {
+	records := newTestRecords(50)
+	store := &fakeStore{pending: slices.Clone(records)}
+	relay, broker, _ := newTestRelay(store, 4)
+	other := NewRelay(store, NewTransportPublisher(broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey})), 4, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, r := range []*Relay{relay, other} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Run(ctx)
+		}()
+	}
+	deadline := time.After(2 * time.Second)
+	for store.pendingCount() > 0 {
+		select {
+		case <-deadline:
+			t.Fatalf("%d records still pending", store.pendingCount())
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	wg.Wait()
+
+	var want []string
+	for _, record := range records {
+		want = append(want, string(record.Payload))
+	}
+	if got := topicValues(t, broker, "events-processed"); !slices.Equal(got, want) {
+		t.Fatalf("published %v, want each record once in order %v", got, want)
+	}
+}
+
+func TestRelayBatchMarksPublishedRecords(t *testing.T) {
+	records := newTestRecords(5)
+	store := &fakeStore{pending: slices.Clone(records)}
+	relay, broker, _ := newTestRelay(store, 3)
+
+	n, err := relay.relayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("relayed %d records, want a full batch of 3", n)
+	}
+	if want := []string{"event-0", "event-1", "event-2"}; !slices.Equal(store.published, want) {
+		t.Fatalf("marked %v, want %v", store.published, want)
+	}
+	if store.pendingCount() != 2 {
+		t.Fatalf("%d records pending, want 2", store.pendingCount())
+	}
+
+	var keys []string
+	err = broker.Read(context.Background(), "events-processed", 0, 0, 10, func(msg transport.Message) bool {
+		keys = append(keys, string(msg.Key))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(keys, []string{"author", "author", "author"}) {
+		t.Fatalf("published with keys %v, want the record keys", keys)
+	}
+}
+
+func TestRelayBatchLeavesRecordsForRetryOnError(t *testing.T) {
+	records := newTestRecords(2)
+	store := &fakeStore{pending: slices.Clone(records)}
+	relay, broker, publisher := newTestRelay(store, 10)
+
+	publisher.err = errUnavailable
+	if n, err := relay.relayBatch(context.Background()); !errors.Is(err, errUnavailable) || n != 0 {
+		t.Fatalf("got %d, %v, want the publish error", n, err)
+	}
+	if store.pendingCount() != 2 || len(store.published) != 0 {
+		t.Fatal("marked records whose publish failed")
+	}
+
+	// A failure to mark leaves the published records pending, so they are
+	// published again: delivery is at-least-once.
+	publisher.err = nil
+	store.markErr = errUnavailable
+	if _, err := relay.relayBatch(context.Background()); !errors.Is(err, errUnavailable) {
+		t.Fatalf("got %v, want the mark error", err)
+	}
+	if store.pendingCount() != 2 {
+		t.Fatal("records left the outbox without being marked")
+	}
+
+	store.markErr = nil
+	if n, err := relay.relayBatch(context.Background()); err != nil || n != 2 {
+		t.Fatalf("got %d, %v, want both records relayed", n, err)
+	}
+	if store.pendingCount() != 0 {
+		t.Fatal("records still pending after a successful batch")
+	}
+	want := []string{`{"n":0}`, `{"n":1}`, `{"n":0}`, `{"n":1}`}
+	if got := topicValues(t, broker, "events-processed"); !slices.Equal(got, want) {
+		t.Fatalf("published %v, want %v", got, want)
+	}
+}
+
+func TestTransportPublisherKeysRecordsByIDWithoutKey(t *testing.T) {
+	broker := memory.New(1)
+	publisher := NewTransportPublisher(broker.NewPublisher(transport.PublisherConfig{}))
+	err := publisher.PublishRecords(context.Background(), []Record{{ID: "event-1", Topic: "events-processed", Payload: []byte("{}")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = broker.Read(context.Background(), "events-processed", 0, 0, 1, func(msg transport.Message) bool {
+		if string(msg.Key) != "event-1" {
+			t.Fatalf("got key %q, want the record id", msg.Key)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"feed-api/internal/messaging"
+	"feed-api/internal/tags"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Event types and current schema version of message changes.
+const (
+	MessageDeletedEventType   = "message.deleted"
+	MessageEditedEventType    = "message.edited"
+	MessageChangeEventVersion = "1.0"
+)
+
+type ChangeKind string
+
+const (
+	ChangeDeleted ChangeKind = "deleted"
+	ChangeEdited  ChangeKind = "edited"
+)
+
+// MessageChange is an author's deletion or edit of a message. Each change has
+// its own id, so redelivering it is a no-op.
+type MessageChange struct {
+	id        string
+	kind      ChangeKind
+	messageID string
+	userID    string
+	content   string
+	changedAt time.Time
+}
+
+func NewMessageDeletion(messageID, userID string) *MessageChange {
+	return newMessageChange(ChangeDeleted, messageID, userID, "")
+}
+
+func NewMessageEdit(messageID, userID, content string) *MessageChange {
+	return newMessageChange(ChangeEdited, messageID, userID, content)
+}
+
+func newMessageChange(kind ChangeKind, messageID, userID, content string) *MessageChange {
+	return &MessageChange{
+		id:        uuid.New().String(),
+		kind:      kind,
+		messageID: messageID,
+		userID:    userID,
+		content:   content,
+		changedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+func (c *MessageChange) UnmarshalJSON(data []byte) error {
+	type Payload struct {
+		ID        string     `json:"id"`
+		Kind      ChangeKind `json:"kind"`
+		MessageID string     `json:"message_id"`
+		UserID    string     `json:"user_id"`
+		Content   string     `json:"content,omitempty"`
+		ChangedAt time.Time  `json:"changed_at"`
+	}
+	var decodedPayload Payload
+	if err := json.Unmarshal(data, &decodedPayload); err != nil {
+		return err
+	}
+	c.id = decodedPayload.ID
+	c.kind = decodedPayload.Kind
+	c.messageID = decodedPayload.MessageID
+	c.userID = decodedPayload.UserID
+	c.content = decodedPayload.Content
+	c.changedAt = decodedPayload.ChangedAt
+	return nil
+}
+
+func (c *MessageChange) MarshalJSON() ([]byte, error) {
+	type Payload struct {
+		ID        string     `json:"id"`
+		Kind      ChangeKind `json:"kind"`
+		MessageID string     `json:"message_id"`
+		UserID    string     `json:"user_id"`
+		Content   string     `json:"content,omitempty"`
+		ChangedAt time.Time  `json:"changed_at"`
+	}
+	encodedPayload := &Payload{
+		ID:        c.id,
+		Kind:      c.kind,
+		MessageID: c.messageID,
+		UserID:    c.userID,
+		Content:   c.content,
+		ChangedAt: c.changedAt,
+	}
+	return json.Marshal(encodedPayload)
+}
+
+func (c *MessageChange) EventType() string {
+	if c.kind == ChangeDeleted {
+		return MessageDeletedEventType
+	}
+	return MessageEditedEventType
+}
+
+func (c *MessageChange) ID() string {
+	return c.id
+}
+
+func (c *MessageChange) Kind() ChangeKind {
+	return c.kind
+}
+
+func (c *MessageChange) MessageID() string {
+	return c.messageID
+}
+
+// UserID is the author of the changed message, so changes are partitioned
+// with the messages they change.
+func (c *MessageChange) UserID() string {
+	return c.userID
+}
+
+// Content is the new content of an edit.
+func (c *MessageChange) Content() string {
+	return c.content
+}
+
+func (c *MessageChange) ChangedAt() time.Time {
+	return c.changedAt
+}
+
+// ApplyChange soft-deletes or edits a message and, in the same transaction,
+// writes the outbox event announcing it. Deleting a reply lowers the reply
+// count of its parent, unless the parent is deleted too; an edit keeps the previous content in message_edits
+// and re-indexes the message's hashtags and mentions. Changes to messages
+// that do not exist, are already deleted or belong to another user are
+// ignored, as are changes that were applied before.
+func (r *CockroachRepo) ApplyChange(ctx context.Context, change *MessageChange) error {
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		var (
+			tag pgconn.CommandTag
+			err error
+		)
+		switch change.kind {
+		case ChangeDeleted:
+			query := `
+				UPDATE messages SET deleted_at = $3
+				WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+			`
+			tag, err = tx.Exec(ctx, query, change.messageID, change.userID, change.changedAt)
+			if err == nil && tag.RowsAffected() > 0 {
+				query = `
+					UPDATE messages SET reply_count = reply_count - 1
+					WHERE id = (SELECT in_reply_to FROM messages WHERE id = $1) AND reply_count > 0 AND deleted_at IS NULL
+				`
+				_, err = tx.Exec(ctx, query, change.messageID)
+			}
+		case ChangeEdited:
+			query := `
+				INSERT INTO message_edits (id, message_id, previous_content, edited_at)
+				SELECT $1, id, content, $4 FROM messages
+				WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+				ON CONFLICT (id) DO NOTHING
+			`
+			tag, err = tx.Exec(ctx, query, change.id, change.messageID, change.userID, change.changedAt)
+			if err == nil && tag.RowsAffected() > 0 {
+				query = `UPDATE messages SET content = $2, edited_at = $3 WHERE id = $1 RETURNING created_at`
+				var createdAt time.Time
+				err = tx.QueryRow(ctx, query, change.messageID, change.content, change.changedAt).Scan(&createdAt)
+				if err == nil {
+					err = replaceTags(ctx, tx, change.messageID, change.userID, createdAt, tags.Extract(change.content))
+				}
+			}
+		default:
+			return fmt.Errorf("unknown change kind %q", change.kind)
+		}
+		if err != nil || tag.RowsAffected() == 0 {
+			return err
+		}
+
+		return r.writeOutbox(ctx, tx, messaging.NewEventMessageWithID[messaging.Eventable](change.EventType()+":"+change.id, change))
+	})
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+// countRows returns the number of rows query counts for id.
+func countRows(t *testing.T, repo *CockroachRepo, query, id string) int {
+	t.Helper()
+	var n int
+	if err := repo.conn.QueryRow(context.Background(), query, id).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestApplyChangeTwiceIsNoOp(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	parent := saveTestMessage(t, repo, NewMessage("user-1", "parent"))
+	reply := saveTestMessage(t, repo, NewReply("user-2", "first draft", parent.ID()))
+	saveTestMessage(t, repo, NewReply("user-3", "another reply", parent.ID()))
+
+	edit := NewMessageEdit(reply.ID(), "user-2", "second draft")
+	deletion := NewMessageDeletion(reply.ID(), "user-2")
+	for _, change := range []*MessageChange{edit, edit, deletion, deletion} {
+		if err := repo.ApplyChange(ctx, change); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := countRows(t, repo, `SELECT count(*) FROM message_edits WHERE message_id = $1`, reply.ID()); n != 1 {
+		t.Errorf("got %d edits, want 1", n)
+	}
+	for _, change := range []*MessageChange{edit, deletion} {
+		if n := countRows(t, repo, `SELECT count(*) FROM outbox WHERE id = $1`, change.EventType()+":"+change.ID()); n != 1 {
+			t.Errorf("got %d outbox events for the %s change, want 1", n, change.Kind())
+		}
+	}
+
+	_, status, err := repo.GetMessageStatus(ctx, reply.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != StatusDeleted {
+		t.Errorf("got status %s, want %s", status, StatusDeleted)
+	}
+	stored, _, err := repo.GetMessageStatus(ctx, parent.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ReplyCount() != 1 {
+		t.Errorf("got reply count %d, want the deletion counted once", stored.ReplyCount())
+	}
+
+	// A replayed edit of the deleted message does not bring it back.
+	if err = repo.ApplyChange(ctx, edit); err != nil {
+		t.Fatal(err)
+	}
+	if _, status, _ = repo.GetMessageStatus(ctx, reply.ID()); status != StatusDeleted {
+		t.Errorf("got status %s after replaying the edit, want %s", status, StatusDeleted)
+	}
+}
+
+func TestRepliesDoNotCountOnDeletedParents(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	parent := saveTestMessage(t, repo, NewMessage("user-1", "parent"))
+	earlier := saveTestMessage(t, repo, NewReply("user-2", "before the deletion", parent.ID()))
+	if err := repo.ApplyChange(ctx, NewMessageDeletion(parent.ID(), "user-1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reply accepted before the parent was deleted reaches the worker after.
+	late := NewReply("user-3", "after the deletion", parent.ID())
+	if err := repo.SaveMessages(ctx, []*Message{late}); err != nil {
+		t.Fatal(err)
+	}
+	if late.ConversationID() != parent.ConversationID() {
+		t.Errorf("late reply is in conversation %s, want the parent's %s", late.ConversationID(), parent.ConversationID())
+	}
+	const replyCount = `SELECT reply_count FROM messages WHERE id = $1`
+	if n := countRows(t, repo, replyCount, parent.ID()); n != 1 {
+		t.Fatalf("got reply count %d after a reply to the deleted parent, want 1", n)
+	}
+
+	// Deleting either reply leaves the deleted parent's count alone.
+	for _, reply := range []*Message{late, earlier} {
+		if err := repo.ApplyChange(ctx, NewMessageDeletion(reply.ID(), reply.UserID())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := countRows(t, repo, replyCount, parent.ID()); n != 1 {
+		t.Fatalf("got reply count %d after deleting the replies, want 1", n)
+	}
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a keyset position over messages ordered by (created_at, id).
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func (c Cursor) IsZero() bool {
+	return c.CreatedAt.IsZero() && c.ID == ""
+}
+
+// After reports whether c sorts strictly after other in (created_at, id) order.
+func (c Cursor) After(other Cursor) bool {
+	if !c.CreatedAt.Equal(other.CreatedAt) {
+		return c.CreatedAt.After(other.CreatedAt)
+	}
+	return c.ID > other.ID
+}
+
+// Compare returns -1, 0 or +1 as c sorts before, equal to or after other.
+func (c Cursor) Compare(other Cursor) int {
+	if n := c.CreatedAt.Compare(other.CreatedAt); n != 0 {
+		return n
+	}
+	return strings.Compare(c.ID, other.ID)
+}
+
+func (c Cursor) Encode() string {
+	if c.IsZero() {
+		return ""
+	}
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if _, err = uuid.Parse(id); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{CreatedAt: t, ID: id}, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, cursor := range []Cursor{
+		{},
+		{CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), ID: uuid.NewString()},
+		{CreatedAt: time.Date(2025, 6, 30, 23, 59, 59, 123456000, time.UTC), ID: uuid.NewString()},
+	} {
+		decoded, err := DecodeCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("decoding %+v: %v", cursor, err)
+		}
+		if decoded.Compare(cursor) != 0 || decoded.IsZero() != cursor.IsZero() {
+			t.Fatalf("got %+v, want %+v", decoded, cursor)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	id := uuid.NewString()
+	for name, s := range map[string]string{
+		"not base64":       "not a cursor!",
+		"no separator":     encode("2025-01-01T00:00:00Z" + id),
+		"id not a uuid":    encode("2025-01-01T00:00:00Z|42"),
+		"time not rfc3339": encode("yesterday|" + id),
+		"empty time":       encode("|" + id),
+		"truncated":        encode("2025-01-01T00:00:00Z|" + id)[:20],
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("got %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestGetMessagesBeforePagesThroughEqualTimestamps(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	want := make(map[string]bool)
+	for range 5 {
+		msg := NewMessage("author", "same instant")
+		msg.createdAt = createdAt
+		saveTestMessage(t, repo, msg)
+		want[msg.ID()] = true
+	}
+
+	var (
+		before Cursor
+		pages  int
+	)
+	for {
+		messages, err := repo.GetMessagesBefore(ctx, before, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, msg := range messages {
+			if !want[msg.ID()] {
+				t.Fatalf("got message %s twice or unexpectedly", msg.ID())
+			}
+			delete(want, msg.ID())
+		}
+		if len(messages) < 2 {
+			break
+		}
+		pages++
+		before = messages[len(messages)-1].Cursor()
+	}
+	if len(want) != 0 || pages != 2 {
+		t.Fatalf("missed %d messages over %d full pages", len(want), pages)
+	}
+}
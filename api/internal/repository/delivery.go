@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type DeliveryStatus string
+
+const (
+	StatusQueued    DeliveryStatus = "queued"
+	StatusPersisted DeliveryStatus = "persisted"
+	StatusFailed    DeliveryStatus = "failed"
+	StatusDeleted   DeliveryStatus = "deleted"
+)
+
+// MarkQueued records that msg was accepted and handed to the pipeline. A
+// failed message that is queued again, e.g. on an idempotent replay, goes back
+// to queued.
+func (r *CockroachRepo) MarkQueued(ctx context.Context, msg *Message) error {
+	query := `
+		INSERT INTO message_deliveries (id, user_id, status, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, error = NULL, updated_at = now()
+		WHERE message_deliveries.status = $5
+	`
+	_, err := r.conn.Exec(ctx, query, msg.id, msg.userID, StatusQueued, msg.createdAt, StatusFailed)
+	return err
+}
+
+func (r *CockroachRepo) MarkFailed(ctx context.Context, msg *Message, reason string) error {
+	query := `
+		UPSERT INTO message_deliveries (id, user_id, status, error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, now())
+	`
+	_, err := r.conn.Exec(ctx, query, msg.id, msg.userID, StatusFailed, reason, msg.createdAt)
+	return err
+}
+
+// GetMessageStatus reports where the message with the given id is in the
+// pipeline. Persisted messages are returned in full; queued, failed and
+// deleted ones carry no content. It returns ErrNotFound for unknown ids.
+func (r *CockroachRepo) GetMessageStatus(ctx context.Context, id string) (*Message, DeliveryStatus, error) {
+	query := `
+		SELECT id, user_id, content, created_at, edited_at, deleted_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count
+		FROM messages WHERE id = $1
+	`
+	msg, err := scanThreadMessage(r.conn.QueryRow(ctx, query, id))
+	if err == nil {
+		if !msg.deletedAt.IsZero() {
+			return msg, StatusDeleted, nil
+		}
+		return msg, StatusPersisted, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", err
+	}
+
+	query = `SELECT id, user_id, created_at, status FROM message_deliveries WHERE id = $1`
+
+	var (
+		queued Message
+		status DeliveryStatus
+	)
+	err = r.conn.QueryRow(ctx, query, id).Scan(&queued.id, &queued.userID, &queued.createdAt, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &queued, status, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// followBackfillLimit is how many of the followee's recent messages are copied
+// into the follower's timeline when they follow someone.
+const followBackfillLimit = 100
+
+func (r *CockroachRepo) Follow(ctx context.Context, followerID, followeeID string) error {
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO follows (follower_id, followee_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`
+		tag, err := tx.Exec(ctx, query, followerID, followeeID)
+		if err != nil || tag.RowsAffected() == 0 {
+			return err
+		}
+
+		query = `
+			INSERT INTO follower_counts (user_id, followers)
+			VALUES ($1, 1)
+			ON CONFLICT (user_id) DO UPDATE SET followers = follower_counts.followers + 1
+		`
+		if _, err = tx.Exec(ctx, query, followeeID); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO timeline_entries (user_id, created_at, message_id, author_id)
+			SELECT $1, created_at, id, user_id FROM messages
+			WHERE user_id = $2
+			ORDER BY created_at DESC
+			LIMIT $3
+			ON CONFLICT DO NOTHING
+		`
+		_, err = tx.Exec(ctx, query, followerID, followeeID, followBackfillLimit)
+		return err
+	})
+}
+
+func (r *CockroachRepo) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		query := `DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2`
+		tag, err := tx.Exec(ctx, query, followerID, followeeID)
+		if err != nil || tag.RowsAffected() == 0 {
+			return err
+		}
+
+		query = `UPDATE follower_counts SET followers = followers - 1 WHERE user_id = $1`
+		if _, err = tx.Exec(ctx, query, followeeID); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM timeline_entries WHERE user_id = $1 AND author_id = $2`
+		_, err = tx.Exec(ctx, query, followerID, followeeID)
+		return err
+	})
+}
+
+// GetFollowees returns the ids of the users followerID follows.
+func (r *CockroachRepo) GetFollowees(ctx context.Context, followerID string) ([]string, error) {
+	query := `SELECT followee_id FROM follows WHERE follower_id = $1`
+
+	rows, err := r.conn.Query(ctx, query, followerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// CountFollowers returns how many users follow userID.
+func (r *CockroachRepo) CountFollowers(ctx context.Context, userID string) (int, error) {
+	query := `SELECT followers FROM follower_counts WHERE user_id = $1`
+
+	var followers int
+	err := r.conn.QueryRow(ctx, query, userID).Scan(&followers)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return followers, err
+}
+
+// GetFollowerIDs returns up to limit followers of userID ordered by id,
+// starting after the given follower id.
+func (r *CockroachRepo) GetFollowerIDs(ctx context.Context, userID, after string, limit int) ([]string, error) {
+	query := `
+		SELECT follower_id FROM follows
+		WHERE followee_id = $1 AND follower_id > $2
+		ORDER BY follower_id
+		LIMIT $3
+	`
+
+	rows, err := r.conn.Query(ctx, query, userID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
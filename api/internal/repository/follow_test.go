@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestFollowAndUnfollowAreIdempotent(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	assertFollowing := func(step string, want []string) {
+		t.Helper()
+		followees, err := repo.GetFollowees(ctx, "reader")
+		if err != nil {
+			t.Fatal(err)
+		}
+		followers, err := repo.CountFollowers(ctx, "author")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(followees, want) || followers != len(want) {
+			t.Fatalf("after %s: reader follows %v and author has %d followers, want %v and %d", step, followees, followers, want, len(want))
+		}
+	}
+
+	for range 2 {
+		if err := repo.Follow(ctx, "reader", "author"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertFollowing("following twice", []string{"author"})
+
+	if err := repo.Unfollow(ctx, "reader", "stranger"); err != nil {
+		t.Fatal(err)
+	}
+	assertFollowing("unfollowing someone not followed", []string{"author"})
+
+	for range 2 {
+		if err := repo.Unfollow(ctx, "reader", "author"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertFollowing("unfollowing twice", nil)
+}
+
+func TestHomeTimelineOnlyHasFolloweesMessages(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	if err := repo.Follow(ctx, "reader", "author"); err != nil {
+		t.Fatal(err)
+	}
+	followed := saveTestMessage(t, repo, NewMessage("author", "followed"))
+	saveTestMessage(t, repo, NewMessage("stranger", "not followed"))
+	saveTestMessage(t, repo, NewMessage("reader", "own message"))
+
+	messages, err := repo.GetHomeTimelineBefore(ctx, "reader", Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].ID() != followed.ID() {
+		t.Fatalf("got %d messages, want only the followee's", len(messages))
+	}
+}
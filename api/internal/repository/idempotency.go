@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ReserveIdempotencyKey records msg under its author's idempotency key. If an
+// unexpired record already holds the key, the message stored with it is
+// returned and reserved is false. Expired rows are removed by row-level TTL and
+// are overwritten here if the TTL job has not run yet.
+func (r *CockroachRepo) ReserveIdempotencyKey(ctx context.Context, key string, msg *Message, ttl time.Duration) (stored *Message, reserved bool, err error) {
+	query := `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, message_id, content, in_reply_to, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($7, '')::UUID, $5, now() + $6::INTERVAL)
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE SET
+			message_id = excluded.message_id,
+			content = excluded.content,
+			in_reply_to = excluded.in_reply_to,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at < now()
+		RETURNING message_id
+	`
+	var id string
+	err = r.conn.QueryRow(ctx, query, msg.userID, key, msg.id, msg.content, msg.createdAt, ttl, msg.inReplyTo).Scan(&id)
+	if err == nil {
+		return msg, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+
+	query = `
+		SELECT message_id, user_id, content, in_reply_to, created_at FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2
+	`
+	var (
+		existing  Message
+		inReplyTo *string
+	)
+	err = r.conn.QueryRow(ctx, query, msg.userID, key).Scan(&existing.id, &existing.userID, &existing.content, &inReplyTo, &existing.createdAt)
+	if err != nil {
+		return nil, false, err
+	}
+	if inReplyTo != nil {
+		existing.inReplyTo = *inReplyTo
+	}
+	return &existing, false, nil
+}
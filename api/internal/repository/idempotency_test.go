@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	parent := saveTestMessage(t, repo, NewMessage("user-2", "parent"))
+
+	original := NewReply("user-1", "hello", parent.ID())
+	stored, reserved, err := repo.ReserveIdempotencyKey(ctx, "key-1", original, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reserved || stored != original {
+		t.Fatalf("got reserved %v, want the key reserved for the new message", reserved)
+	}
+
+	// A retry gets the original message back, whatever it sends this time.
+	retry := NewMessage("user-1", "something else")
+	stored, reserved, err = repo.ReserveIdempotencyKey(ctx, "key-1", retry, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reserved {
+		t.Fatal("reserved a key that is in use")
+	}
+	if stored.ID() != original.ID() || stored.Content() != "hello" || stored.InReplyTo() != parent.ID() || !stored.CreatedAt().Equal(original.CreatedAt()) {
+		t.Fatalf("got %s %q replying to %q, want the original message", stored.ID(), stored.Content(), stored.InReplyTo())
+	}
+
+	// Keys are scoped to their user.
+	other := NewMessage("user-2", "hello")
+	if _, reserved, err = repo.ReserveIdempotencyKey(ctx, "key-1", other, time.Hour); err != nil || !reserved {
+		t.Fatalf("got reserved %v, %v for another user, want the key reserved", reserved, err)
+	}
+
+	// An expired key the TTL job has not removed yet is taken over.
+	if _, err = repo.conn.Exec(ctx, `UPDATE idempotency_keys SET expires_at = now() - INTERVAL '1s' WHERE user_id = $1`, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	fresh := NewMessage("user-1", "after expiry")
+	stored, reserved, err = repo.ReserveIdempotencyKey(ctx, "key-1", fresh, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reserved || stored.ID() != fresh.ID() {
+		t.Fatal("expired key was not reserved for the new message")
+	}
+	if stored, reserved, err = repo.ReserveIdempotencyKey(ctx, "key-1", retry, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if reserved || stored.ID() != fresh.ID() || stored.InReplyTo() != "" {
+		t.Fatalf("got %s replying to %q, want the message that took over the key", stored.ID(), stored.InReplyTo())
+	}
+}
@@ -2,33 +2,77 @@ package repository
 
 import (
 	"encoding/json"
+	"feed-api/internal/messaging"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Event type and current schema version of a posted message.
+const (
+	MessageEventType    = messaging.DefaultEventType
+	MessageEventVersion = "1.0"
+)
+
+// RegisterEvents registers the event data of this package with r, so events
+// of every type can be decoded from a shared topic.
+func RegisterEvents(r *messaging.Registry) {
+	messaging.Register[*Message](r, MessageEventType, MessageEventVersion)
+	messaging.Register[*MessageChange](r, MessageDeletedEventType, MessageChangeEventVersion)
+	messaging.Register[*MessageChange](r, MessageEditedEventType, MessageChangeEventVersion)
+	messaging.Register[*MessageStats](r, MessageStatsEventType, MessageStatsEventVersion)
+	messaging.Register[*Repost](r, RepostEventType, RepostEventVersion)
+}
+
 type Message struct {
 	id        string
 	userID    string
 	content   string
 	createdAt time.Time
+	editedAt  time.Time
+	deletedAt time.Time
+	// inReplyTo is chosen by the author; conversationID and depth are set by
+	// the worker when the message is saved.
+	inReplyTo      string
+	conversationID string
+	depth          int
+	replyCount     int
+	likeCount      int
+	repostCount    int
 }
 
 func NewMessage(userID, content string) *Message {
 	return &Message{
-		id:        uuid.New().String(),
-		userID:    userID,
-		content:   content,
-		createdAt: time.Now(),
+		id:      uuid.New().String(),
+		userID:  userID,
+		content: content,
+		// TIMESTAMPTZ keeps microseconds; truncating here keeps cursors built
+		// from live events equal to the ones read back from the database.
+		createdAt: time.Now().UTC().Truncate(time.Microsecond),
 	}
 }
 
+// NewReply creates a message answering the message with id inReplyTo.
+func NewReply(userID, content, inReplyTo string) *Message {
+	msg := NewMessage(userID, content)
+	msg.inReplyTo = inReplyTo
+	return msg
+}
+
 func (m *Message) UnmarshalJSON(data []byte) error {
 	type Payload struct {
-		ID        string    `json:"id"`
-		UserID    string    `json:"user_id"`
-		Content   string    `json:"content"`
-		CreatedAt time.Time `json:"created_at"`
+		ID             string    `json:"id"`
+		UserID         string    `json:"user_id"`
+		Content        string    `json:"content"`
+		CreatedAt      time.Time `json:"created_at"`
+		EditedAt       time.Time `json:"edited_at,omitzero"`
+		DeletedAt      time.Time `json:"deleted_at,omitzero"`
+		InReplyTo      string    `json:"in_reply_to,omitempty"`
+		ConversationID string    `json:"conversation_id,omitempty"`
+		Depth          int       `json:"depth,omitempty"`
+		ReplyCount     int       `json:"reply_count"`
+		LikeCount      int       `json:"like_count"`
+		RepostCount    int       `json:"repost_count"`
 	}
 	var decodedPayload Payload
 	if err := json.Unmarshal(data, &decodedPayload); err != nil {
@@ -38,21 +82,108 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 	m.userID = decodedPayload.UserID
 	m.content = decodedPayload.Content
 	m.createdAt = decodedPayload.CreatedAt
+	m.editedAt = decodedPayload.EditedAt
+	m.deletedAt = decodedPayload.DeletedAt
+	m.inReplyTo = decodedPayload.InReplyTo
+	m.conversationID = decodedPayload.ConversationID
+	m.depth = decodedPayload.Depth
+	m.replyCount = decodedPayload.ReplyCount
+	m.likeCount = decodedPayload.LikeCount
+	m.repostCount = decodedPayload.RepostCount
 	return nil
 }
 
 func (m *Message) MarshalJSON() ([]byte, error) {
 	type Payload struct {
-		ID        string    `json:"id"`
-		UserID    string    `json:"user_id"`
-		Content   string    `json:"content"`
-		CreatedAt time.Time `json:"created_at"`
+		ID             string    `json:"id"`
+		UserID         string    `json:"user_id"`
+		Content        string    `json:"content"`
+		CreatedAt      time.Time `json:"created_at"`
+		EditedAt       time.Time `json:"edited_at,omitzero"`
+		DeletedAt      time.Time `json:"deleted_at,omitzero"`
+		InReplyTo      string    `json:"in_reply_to,omitempty"`
+		ConversationID string    `json:"conversation_id,omitempty"`
+		Depth          int       `json:"depth,omitempty"`
+		ReplyCount     int       `json:"reply_count"`
+		LikeCount      int       `json:"like_count"`
+		RepostCount    int       `json:"repost_count"`
 	}
 	encodedPayload := &Payload{
-		ID:        m.id,
-		UserID:    m.userID,
-		Content:   m.content,
-		CreatedAt: m.createdAt,
+		ID:             m.id,
+		UserID:         m.userID,
+		Content:        m.content,
+		CreatedAt:      m.createdAt,
+		EditedAt:       m.editedAt,
+		DeletedAt:      m.deletedAt,
+		InReplyTo:      m.inReplyTo,
+		ConversationID: m.conversationID,
+		Depth:          m.depth,
+		ReplyCount:     m.replyCount,
+		LikeCount:      m.likeCount,
+		RepostCount:    m.repostCount,
 	}
 	return json.Marshal(encodedPayload)
 }
+
+func (m *Message) EventType() string {
+	return MessageEventType
+}
+
+func (m *Message) ID() string {
+	return m.id
+}
+
+func (m *Message) UserID() string {
+	return m.userID
+}
+
+func (m *Message) Content() string {
+	return m.content
+}
+
+func (m *Message) CreatedAt() time.Time {
+	return m.createdAt
+}
+
+// EditedAt is zero for messages that were never edited.
+func (m *Message) EditedAt() time.Time {
+	return m.editedAt
+}
+
+// DeletedAt is zero unless the message was deleted. Deleted messages are only
+// returned as placeholders in threads, without content.
+func (m *Message) DeletedAt() time.Time {
+	return m.deletedAt
+}
+
+// InReplyTo is the id of the message this one answers, or empty.
+func (m *Message) InReplyTo() string {
+	return m.inReplyTo
+}
+
+// ConversationID is the id of the root message of the thread. Messages that
+// are no reply start their own conversation.
+func (m *Message) ConversationID() string {
+	return m.conversationID
+}
+
+// Depth is the number of replies between the message and its conversation's root.
+func (m *Message) Depth() int {
+	return m.depth
+}
+
+func (m *Message) ReplyCount() int {
+	return m.replyCount
+}
+
+func (m *Message) LikeCount() int {
+	return m.likeCount
+}
+
+func (m *Message) RepostCount() int {
+	return m.repostCount
+}
+
+func (m *Message) Cursor() Cursor {
+	return Cursor{CreatedAt: m.createdAt, ID: m.id}
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feed-api/internal/messaging"
+	"feed-api/internal/outbox"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// outboxKey is the de-duplication key of the event announcing a saved message.
+func outboxKey(msg *Message) string {
+	return "message:" + msg.id
+}
+
+// writeOutbox stores events in the outbox within tx, in order. Writing the
+// same event twice is a no-op.
+func (r *CockroachRepo) writeOutbox(ctx context.Context, tx pgx.Tx, events ...*messaging.Event[messaging.Eventable]) error {
+	ids := make([]string, 0, len(events))
+	keys := make([]string, 0, len(events))
+	payloads := make([][]byte, 0, len(events))
+	seqs := make([]int64, 0, len(events))
+	for i, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, event.ID())
+		keys = append(keys, messaging.PartitionKey(event.ID(), event.Data()))
+		payloads = append(payloads, payload)
+		seqs = append(seqs, int64(i))
+	}
+
+	// Rows of one transaction share created_at; seq keeps them in order.
+	query := `
+		INSERT INTO outbox (id, topic, partition_key, payload, seq)
+		SELECT id, $2, partition_key, payload, seq
+		FROM unnest($1::STRING[], $3::STRING[], $4::BYTES[], $5::INT8[])
+			AS t(id, partition_key, payload, seq)
+		ON CONFLICT (id) DO NOTHING
+	`
+	_, err := tx.Exec(ctx, query, ids, r.outboxTopic, keys, payloads, seqs)
+	return err
+}
+
+// RelayPendingOutbox hands up to limit unpublished records, in write order, to
+// publish and marks them published once it returns nil, all in one
+// transaction. The transaction first locks the single outbox_relay_lock row,
+// so relays on other instances skip their turn instead of publishing the same
+// records, or later records of the same key ahead of them. Locking the
+// records themselves with SKIP LOCKED would let a second relay publish the
+// next batch before the first one is done.
+func (r *CockroachRepo) RelayPendingOutbox(ctx context.Context, limit int, publish func(records []outbox.Record) error) (int, error) {
+	var n int
+	err := pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		var locked int
+		err := tx.QueryRow(ctx, `SELECT id FROM outbox_relay_lock WHERE id = 1 FOR UPDATE SKIP LOCKED`).Scan(&locked)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		query := `
+			SELECT id, topic, partition_key, payload, created_at FROM outbox
+			WHERE published_at IS NULL
+			ORDER BY created_at, seq, id
+			LIMIT $1
+		`
+		rows, err := tx.Query(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+		records, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (outbox.Record, error) {
+			var record outbox.Record
+			err := row.Scan(&record.ID, &record.Topic, &record.Key, &record.Payload, &record.CreatedAt)
+			return record, err
+		})
+		if err != nil || len(records) == 0 {
+			return err
+		}
+
+		if err = publish(records); err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(records))
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		if _, err = tx.Exec(ctx, `UPDATE outbox SET published_at = now() WHERE id = ANY($1)`, ids); err != nil {
+			return err
+		}
+		n = len(records)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"feed-api/internal/outbox"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentRelaysPublishEachRecordOnce(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	var want []string
+	for n := range 30 {
+		msg := saveTestMessage(t, repo, NewMessage("user-1", fmt.Sprintf("message %d", n)))
+		want = append(want, outboxKey(msg))
+	}
+
+	var (
+		mu        sync.Mutex
+		published []string
+		wg        sync.WaitGroup
+	)
+	publish := func(records []outbox.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, record := range records {
+			published = append(published, record.ID)
+		}
+		// Hold the batch long enough for the other relay to try its turn.
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idle := 0; idle < 20; {
+				n, err := repo.RelayPendingOutbox(ctx, 4, publish)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if n > 0 {
+					idle = 0
+					continue
+				}
+				idle++
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !slices.Equal(published, want) {
+		t.Fatalf("published %v, want each record once in order %v", published, want)
+	}
+}
@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feed-api/internal/messaging"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Event types and current schema versions of reactions to messages.
+const (
+	MessageStatsEventType    = "message.stats"
+	MessageStatsEventVersion = "1.0"
+	RepostEventType          = "repost"
+	RepostEventVersion       = "1.0"
+)
+
+// MessageStats are the counters of a message right after one of them changed.
+type MessageStats struct {
+	id          string
+	messageID   string
+	userID      string
+	likeCount   int
+	repostCount int
+	replyCount  int
+	updatedAt   time.Time
+}
+
+func newMessageStats(msg *Message) *MessageStats {
+	return &MessageStats{
+		id:          uuid.New().String(),
+		messageID:   msg.id,
+		userID:      msg.userID,
+		likeCount:   msg.likeCount,
+		repostCount: msg.repostCount,
+		replyCount:  msg.replyCount,
+		updatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+func (s *MessageStats) UnmarshalJSON(data []byte) error {
+	type Payload struct {
+		ID          string    `json:"id"`
+		MessageID   string    `json:"message_id"`
+		UserID      string    `json:"user_id"`
+		LikeCount   int       `json:"like_count"`
+		RepostCount int       `json:"repost_count"`
+		ReplyCount  int       `json:"reply_count"`
+		UpdatedAt   time.Time `json:"updated_at"`
+	}
+	var decodedPayload Payload
+	if err := json.Unmarshal(data, &decodedPayload); err != nil {
+		return err
+	}
+	s.id = decodedPayload.ID
+	s.messageID = decodedPayload.MessageID
+	s.userID = decodedPayload.UserID
+	s.likeCount = decodedPayload.LikeCount
+	s.repostCount = decodedPayload.RepostCount
+	s.replyCount = decodedPayload.ReplyCount
+	s.updatedAt = decodedPayload.UpdatedAt
+	return nil
+}
+
+func (s *MessageStats) MarshalJSON() ([]byte, error) {
+	type Payload struct {
+		ID          string    `json:"id"`
+		MessageID   string    `json:"message_id"`
+		UserID      string    `json:"user_id"`
+		LikeCount   int       `json:"like_count"`
+		RepostCount int       `json:"repost_count"`
+		ReplyCount  int       `json:"reply_count"`
+		UpdatedAt   time.Time `json:"updated_at"`
+	}
+	encodedPayload := &Payload{
+		ID:          s.id,
+		MessageID:   s.messageID,
+		UserID:      s.userID,
+		LikeCount:   s.likeCount,
+		RepostCount: s.repostCount,
+		ReplyCount:  s.replyCount,
+		UpdatedAt:   s.updatedAt,
+	}
+	return json.Marshal(encodedPayload)
+}
+
+func (s *MessageStats) EventType() string {
+	return MessageStatsEventType
+}
+
+func (s *MessageStats) ID() string {
+	return s.id
+}
+
+func (s *MessageStats) MessageID() string {
+	return s.messageID
+}
+
+// UserID is the author of the message, so stats are partitioned and
+// filtered with the message they count.
+func (s *MessageStats) UserID() string {
+	return s.userID
+}
+
+func (s *MessageStats) LikeCount() int {
+	return s.likeCount
+}
+
+func (s *MessageStats) RepostCount() int {
+	return s.repostCount
+}
+
+func (s *MessageStats) ReplyCount() int {
+	return s.replyCount
+}
+
+func (s *MessageStats) UpdatedAt() time.Time {
+	return s.updatedAt
+}
+
+// Repost is a user sharing someone's message with their own followers.
+type Repost struct {
+	id        string
+	userID    string
+	createdAt time.Time
+	message   *Message
+}
+
+func (p *Repost) UnmarshalJSON(data []byte) error {
+	type Payload struct {
+		ID        string    `json:"id"`
+		UserID    string    `json:"user_id"`
+		CreatedAt time.Time `json:"created_at"`
+		Message   *Message  `json:"message"`
+	}
+	var decodedPayload Payload
+	if err := json.Unmarshal(data, &decodedPayload); err != nil {
+		return err
+	}
+	p.id = decodedPayload.ID
+	p.userID = decodedPayload.UserID
+	p.createdAt = decodedPayload.CreatedAt
+	p.message = decodedPayload.Message
+	return nil
+}
+
+func (p *Repost) MarshalJSON() ([]byte, error) {
+	type Payload struct {
+		ID        string    `json:"id"`
+		UserID    string    `json:"user_id"`
+		CreatedAt time.Time `json:"created_at"`
+		Message   *Message  `json:"message"`
+	}
+	encodedPayload := &Payload{
+		ID:        p.id,
+		UserID:    p.userID,
+		CreatedAt: p.createdAt,
+		Message:   p.message,
+	}
+	return json.Marshal(encodedPayload)
+}
+
+func (p *Repost) EventType() string {
+	return RepostEventType
+}
+
+func (p *Repost) ID() string {
+	return p.id
+}
+
+// UserID is the user who reposted, so reposts reach their followers.
+func (p *Repost) UserID() string {
+	return p.userID
+}
+
+func (p *Repost) CreatedAt() time.Time {
+	return p.createdAt
+}
+
+// Message is the original message as it was when it was reposted, or as it
+// is now for reposts read back from the database.
+func (p *Repost) Message() *Message {
+	return p.message
+}
+
+// Cursor positions the repost by when it was reposted, in the same keyset
+// as messages.
+func (p *Repost) Cursor() Cursor {
+	return Cursor{CreatedAt: p.createdAt, ID: p.id}
+}
+
+// Like records that userID likes the message. Liking a message twice is a
+// no-op; liking a message that does not exist or was deleted returns
+// ErrNotFound.
+func (r *CockroachRepo) Like(ctx context.Context, messageID, userID string) error {
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO likes (message_id, user_id)
+			SELECT id, $2 FROM messages WHERE id = $1 AND deleted_at IS NULL
+			ON CONFLICT DO NOTHING
+		`
+		tag, err := tx.Exec(ctx, query, messageID, userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return checkMessageExists(ctx, tx, messageID)
+		}
+
+		msg, err := r.updateCounters(ctx, tx, messageID, `like_count = like_count + 1`)
+		if err != nil || msg == nil {
+			return err
+		}
+		return r.writeOutbox(ctx, tx, statsEvent(msg))
+	})
+}
+
+func (r *CockroachRepo) Unlike(ctx context.Context, messageID, userID string) error {
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		query := `DELETE FROM likes WHERE message_id = $1 AND user_id = $2`
+		tag, err := tx.Exec(ctx, query, messageID, userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return checkMessageExists(ctx, tx, messageID)
+		}
+
+		msg, err := r.updateCounters(ctx, tx, messageID, `like_count = like_count - 1`)
+		if err != nil || msg == nil {
+			return err
+		}
+		return r.writeOutbox(ctx, tx, statsEvent(msg))
+	})
+}
+
+// Repost shares the message with userID's followers. Besides the new
+// counters it announces the repost itself. Reposting a message twice is a
+// no-op.
+func (r *CockroachRepo) Repost(ctx context.Context, messageID, userID string) error {
+	repost := &Repost{
+		id:        uuid.New().String(),
+		userID:    userID,
+		createdAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO reposts (message_id, user_id, id, created_at)
+			SELECT id, $2, $3, $4 FROM messages WHERE id = $1 AND deleted_at IS NULL
+			ON CONFLICT DO NOTHING
+		`
+		tag, err := tx.Exec(ctx, query, messageID, userID, repost.id, repost.createdAt)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return checkMessageExists(ctx, tx, messageID)
+		}
+
+		msg, err := r.updateCounters(ctx, tx, messageID, `repost_count = repost_count + 1`)
+		if err != nil || msg == nil {
+			return err
+		}
+		repost.message = msg
+		return r.writeOutbox(ctx, tx,
+			messaging.NewEventMessageWithID[messaging.Eventable](RepostEventType+":"+repost.id, repost),
+			statsEvent(msg),
+		)
+	})
+}
+
+// Unrepost removes the repost. Feed clients are only sent the new counters;
+// the repost they may have seen stays in their stream.
+func (r *CockroachRepo) Unrepost(ctx context.Context, messageID, userID string) error {
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		query := `DELETE FROM reposts WHERE message_id = $1 AND user_id = $2`
+		tag, err := tx.Exec(ctx, query, messageID, userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return checkMessageExists(ctx, tx, messageID)
+		}
+
+		msg, err := r.updateCounters(ctx, tx, messageID, `repost_count = repost_count - 1`)
+		if err != nil || msg == nil {
+			return err
+		}
+		return r.writeOutbox(ctx, tx, statsEvent(msg))
+	})
+}
+
+// updateCounters applies set to the message and returns it with the new
+// counters, or nil if it was deleted in the meantime.
+func (r *CockroachRepo) updateCounters(ctx context.Context, tx pgx.Tx, messageID, set string) (*Message, error) {
+	query := `
+		UPDATE messages SET ` + set + `
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, user_id, content, created_at, edited_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count
+	`
+	rows, err := tx.Query(ctx, query, messageID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := collectMessages(rows)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return messages[0], nil
+}
+
+func statsEvent(msg *Message) *messaging.Event[messaging.Eventable] {
+	stats := newMessageStats(msg)
+	return messaging.NewEventMessageWithID[messaging.Eventable](MessageStatsEventType+":"+stats.id, stats)
+}
+
+// checkMessageExists returns ErrNotFound unless the message exists and is
+// not deleted.
+func checkMessageExists(ctx context.Context, tx pgx.Tx, messageID string) error {
+	query := `SELECT 1 FROM messages WHERE id = $1 AND deleted_at IS NULL`
+	var exists int
+	err := tx.QueryRow(ctx, query, messageID).Scan(&exists)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
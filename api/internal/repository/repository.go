@@ -2,46 +2,202 @@ package repository
 
 import (
 	"context"
+	"feed-api/internal/messaging"
+	"feed-api/internal/tags"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// DefaultFanOutThreshold is the follower count above which an author's
+// messages are merged into home timelines at read time instead of being
+// written to every follower's timeline.
+const DefaultFanOutThreshold = 10000
+
+type Option func(*CockroachRepo)
+
+// WithOutboxTopic sets the topic events written to the outbox are published to.
+func WithOutboxTopic(topic string) Option {
+	return func(r *CockroachRepo) {
+		r.outboxTopic = topic
+	}
+}
+
 type CockroachRepo struct {
-	conn *pgxpool.Pool
+	conn        *pgxpool.Pool
+	outboxTopic string
 }
 
-func NewRepository(conn *pgxpool.Pool) *CockroachRepo {
-	return &CockroachRepo{
+func NewRepository(conn *pgxpool.Pool, options ...Option) *CockroachRepo {
+	r := &CockroachRepo{
 		conn: conn,
 	}
+
+	for _, opt := range options {
+		opt(r)
+	}
+
+	return r
 }
 
+// SaveMessage stores msg and, in the same transaction, its hashtags and
+// mentions and the outbox event that announces it. It is idempotent:
+// redelivering an already saved message is a no-op.
 func (r *CockroachRepo) SaveMessage(ctx context.Context, msg *Message) error {
-	query := `
-		INSERT INTO messages (id, user_id, content, created_at)
-		VALUES ($1, $2, $3, $4)
-	`
-	_, err := r.conn.Exec(ctx, query, msg.id, msg.userID, msg.content, msg.createdAt)
-	return err
+	return r.SaveMessages(ctx, []*Message{msg})
 }
 
-func (r *CockroachRepo) GetAllMessages(ctx context.Context) ([]*Message, error) {
-	query := `SELECT id, user_id, content, created_at FROM messages ORDER BY created_at ASC`
+// SaveMessages is SaveMessage for a batch, written with one multi-row insert
+// per table in a single transaction. The outbox events are published in the
+// order of msgs.
+//
+// Replies join the conversation of the message they answer, one level deeper,
+// and bump its reply count unless it was deleted meanwhile: the reply count of
+// a deleted message stays as it was. A reply to a message that is not saved
+// starts its own conversation.
+func (r *CockroachRepo) SaveMessages(ctx context.Context, msgs []*Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
 
-	rows, err := r.conn.Query(ctx, query)
+	ids := make([]string, 0, len(msgs))
+	userIDs := make([]string, 0, len(msgs))
+	contents := make([]string, 0, len(msgs))
+	createdAts := make([]time.Time, 0, len(msgs))
+	inReplyTos := make([]string, 0, len(msgs))
+	byID := make(map[string]*Message, len(msgs))
+	events := make([]*messaging.Event[messaging.Eventable], 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.id)
+		userIDs = append(userIDs, msg.userID)
+		contents = append(contents, msg.content)
+		createdAts = append(createdAts, msg.createdAt)
+		inReplyTos = append(inReplyTos, msg.inReplyTo)
+		byID[msg.id] = msg
+		events = append(events, messaging.NewEventMessageWithID[messaging.Eventable](outboxKey(msg), msg))
+	}
+
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO messages (id, user_id, content, created_at, in_reply_to, conversation_id, depth)
+			SELECT t.id::UUID, t.user_id, t.content, t.created_at, NULLIF(t.in_reply_to, '')::UUID,
+				COALESCE(p.conversation_id, t.id::UUID), COALESCE(p.depth + 1, 0)
+			FROM unnest($1::STRING[], $2::STRING[], $3::STRING[], $4::TIMESTAMPTZ[], $5::STRING[])
+				AS t(id, user_id, content, created_at, in_reply_to)
+			LEFT JOIN messages AS p ON p.id = NULLIF(t.in_reply_to, '')::UUID
+			ON CONFLICT (id) DO NOTHING
+			RETURNING id, conversation_id, depth
+		`
+		rows, err := tx.Query(ctx, query, ids, userIDs, contents, createdAts, inReplyTos)
+		if err != nil {
+			return err
+		}
+		// Only messages saved now are returned; redelivered ones keep their
+		// earlier outbox event.
+		var (
+			id, conversationID string
+			depth              int
+			parents            []string
+			saved              []*Message
+		)
+		_, err = pgx.ForEachRow(rows, []any{&id, &conversationID, &depth}, func() error {
+			msg, ok := byID[id]
+			if !ok {
+				return nil
+			}
+			msg.conversationID, msg.depth = conversationID, depth
+			if msg.inReplyTo != "" {
+				parents = append(parents, msg.inReplyTo)
+			}
+			saved = append(saved, msg)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range saved {
+			set := tags.Extract(msg.content)
+			if set.IsZero() {
+				continue
+			}
+			if err = insertTags(ctx, tx, msg.id, msg.userID, msg.createdAt, set); err != nil {
+				return err
+			}
+		}
+
+		if len(parents) > 0 {
+			query = `
+				UPDATE messages SET reply_count = reply_count + r.replies
+				FROM (SELECT id, count(*) AS replies FROM unnest($1::UUID[]) AS id GROUP BY id) AS r
+				WHERE messages.id = r.id AND messages.deleted_at IS NULL
+			`
+			if _, err = tx.Exec(ctx, query, parents); err != nil {
+				return err
+			}
+		}
+		return r.writeOutbox(ctx, tx, events...)
+	})
+}
+
+// GetMessagesBefore returns up to limit messages strictly older than the cursor,
+// newest first, leaving out deleted messages. A zero cursor starts from the
+// most recent message.
+func (r *CockroachRepo) GetMessagesBefore(ctx context.Context, before Cursor, limit int) ([]*Message, error) {
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	if before.IsZero() {
+		query := `
+			SELECT id, user_id, content, created_at, edited_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count FROM messages
+			WHERE deleted_at IS NULL
+			ORDER BY created_at DESC, id DESC
+			LIMIT $1
+		`
+		rows, err = r.conn.Query(ctx, query, limit)
+	} else {
+		query := `
+			SELECT id, user_id, content, created_at, edited_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count FROM messages
+			WHERE (created_at, id) < ($1, $2::UUID) AND deleted_at IS NULL
+			ORDER BY created_at DESC, id DESC
+			LIMIT $3
+		`
+		rows, err = r.conn.Query(ctx, query, before.CreatedAt, before.ID, limit)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	return collectMessages(rows)
+}
+
+// GetMessagesAfter returns up to limit messages strictly newer than the cursor,
+// oldest first.
+func (r *CockroachRepo) GetMessagesAfter(ctx context.Context, after Cursor, limit int) ([]*Message, error) {
+	query := `
+		SELECT id, user_id, content, created_at, edited_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count FROM messages
+		WHERE (created_at, id) > ($1, $2::UUID) AND deleted_at IS NULL
+		ORDER BY created_at ASC, id ASC
+		LIMIT $3
+	`
+	rows, err := r.conn.Query(ctx, query, after.CreatedAt, after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectMessages(rows)
+}
+
+func collectMessages(rows pgx.Rows) ([]*Message, error) {
 	messages, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Message, error) {
-		var msg Message
-		err := row.Scan(&msg.id, &msg.userID, &msg.content, &msg.createdAt)
-		if err != nil {
+		var scanned messageRow
+		if err := row.Scan(scanned.targets()...); err != nil {
 			return nil, err
 		}
-		return &msg, nil
+		return scanned.message(), nil
 	})
 	if err != nil {
 		return nil, err
@@ -49,3 +205,32 @@ func (r *CockroachRepo) GetAllMessages(ctx context.Context) ([]*Message, error)
 
 	return messages, nil
 }
+
+// messageRow holds a message while its nullable columns are scanned. The
+// columns are id, user_id, content, created_at, edited_at, in_reply_to,
+// conversation_id, depth, reply_count, like_count and repost_count.
+type messageRow struct {
+	msg            Message
+	editedAt       *time.Time
+	inReplyTo      *string
+	conversationID *string
+}
+
+func (m *messageRow) targets() []any {
+	return []any{&m.msg.id, &m.msg.userID, &m.msg.content, &m.msg.createdAt, &m.editedAt,
+		&m.inReplyTo, &m.conversationID, &m.msg.depth, &m.msg.replyCount, &m.msg.likeCount, &m.msg.repostCount}
+}
+
+func (m *messageRow) message() *Message {
+	msg := m.msg
+	if m.editedAt != nil {
+		msg.editedAt = *m.editedAt
+	}
+	if m.inReplyTo != nil {
+		msg.inReplyTo = *m.inReplyTo
+	}
+	if m.conversationID != nil {
+		msg.conversationID = *m.conversationID
+	}
+	return &msg
+}
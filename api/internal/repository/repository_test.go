@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newTestRepository migrates a fresh database on the CockroachDB node at
+// TEST_COCKROACH_ADDR (host:port) and connects to it. Tests that need a
+// database are skipped without it.
+func newTestRepository(t *testing.T, options ...Option) *CockroachRepo {
+	t.Helper()
+	addr := os.Getenv("TEST_COCKROACH_ADDR")
+	if addr == "" {
+		t.Skip("TEST_COCKROACH_ADDR is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := NewConnection(ctx, fmt.Sprintf("postgresql://root@%s/defaultdb?sslmode=disable", addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := "feed_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err = admin.Exec(ctx, "CREATE DATABASE "+database); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "DROP DATABASE "+database+" CASCADE"); err != nil {
+			t.Log("Error dropping test database:", err)
+		}
+		admin.Close()
+	})
+
+	migrateDSN := fmt.Sprintf("cockroachdb://root:@%s/%s?sslmode=disable", addr, database)
+	if err = RunMigrations(migrateDSN, "../../migrations"); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := NewConnection(ctx, fmt.Sprintf("postgresql://root@%s/%s?sslmode=disable", addr, database))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+
+	return NewRepository(conn, options...)
+}
+
+func saveTestMessage(t *testing.T, repo *CockroachRepo, msg *Message) *Message {
+	t.Helper()
+	if err := repo.SaveMessage(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Reposts are paged like messages, by their own (created_at, id) position,
+// so timelines can merge both. Reposts of deleted messages are left out.
+
+// GetRepostsBefore returns up to limit reposts strictly older than the cursor,
+// newest first.
+func (r *CockroachRepo) GetRepostsBefore(ctx context.Context, before Cursor, limit int) ([]*Repost, error) {
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	if before.IsZero() {
+		query := `
+			SELECT p.id, p.user_id, p.created_at,
+				m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count
+			FROM reposts AS p
+			JOIN messages AS m ON m.id = p.message_id
+			WHERE m.deleted_at IS NULL
+			ORDER BY p.created_at DESC, p.id DESC
+			LIMIT $1
+		`
+		rows, err = r.conn.Query(ctx, query, limit)
+	} else {
+		query := `
+			SELECT p.id, p.user_id, p.created_at,
+				m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count
+			FROM reposts AS p
+			JOIN messages AS m ON m.id = p.message_id
+			WHERE m.deleted_at IS NULL AND (p.created_at, p.id) < ($1, $2::UUID)
+			ORDER BY p.created_at DESC, p.id DESC
+			LIMIT $3
+		`
+		rows, err = r.conn.Query(ctx, query, before.CreatedAt, before.ID, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return collectReposts(rows)
+}
+
+// GetRepostsAfter returns up to limit reposts strictly newer than the cursor,
+// oldest first.
+func (r *CockroachRepo) GetRepostsAfter(ctx context.Context, after Cursor, limit int) ([]*Repost, error) {
+	query := `
+		SELECT p.id, p.user_id, p.created_at,
+			m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count
+		FROM reposts AS p
+		JOIN messages AS m ON m.id = p.message_id
+		WHERE m.deleted_at IS NULL AND (p.created_at, p.id) > ($1, $2::UUID)
+		ORDER BY p.created_at ASC, p.id ASC
+		LIMIT $3
+	`
+	rows, err := r.conn.Query(ctx, query, after.CreatedAt, after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectReposts(rows)
+}
+
+// GetHomeRepostsBefore is GetRepostsBefore restricted to reposts by users
+// followerID follows.
+func (r *CockroachRepo) GetHomeRepostsBefore(ctx context.Context, followerID string, before Cursor, limit int) ([]*Repost, error) {
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	if before.IsZero() {
+		query := `
+			SELECT p.id, p.user_id, p.created_at,
+				m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count
+			FROM follows AS f
+			JOIN reposts AS p ON p.user_id = f.followee_id
+			JOIN messages AS m ON m.id = p.message_id
+			WHERE f.follower_id = $1 AND m.deleted_at IS NULL
+			ORDER BY p.created_at DESC, p.id DESC
+			LIMIT $2
+		`
+		rows, err = r.conn.Query(ctx, query, followerID, limit)
+	} else {
+		query := `
+			SELECT p.id, p.user_id, p.created_at,
+				m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count
+			FROM follows AS f
+			JOIN reposts AS p ON p.user_id = f.followee_id
+			JOIN messages AS m ON m.id = p.message_id
+			WHERE f.follower_id = $1 AND m.deleted_at IS NULL AND (p.created_at, p.id) < ($2, $3::UUID)
+			ORDER BY p.created_at DESC, p.id DESC
+			LIMIT $4
+		`
+		rows, err = r.conn.Query(ctx, query, followerID, before.CreatedAt, before.ID, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return collectReposts(rows)
+}
+
+// GetHomeRepostsAfter is GetRepostsAfter restricted to reposts by users
+// followerID follows.
+func (r *CockroachRepo) GetHomeRepostsAfter(ctx context.Context, followerID string, after Cursor, limit int) ([]*Repost, error) {
+	query := `
+		SELECT p.id, p.user_id, p.created_at,
+			m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count
+		FROM follows AS f
+		JOIN reposts AS p ON p.user_id = f.followee_id
+		JOIN messages AS m ON m.id = p.message_id
+		WHERE f.follower_id = $1 AND m.deleted_at IS NULL AND (p.created_at, p.id) > ($2, $3::UUID)
+		ORDER BY p.created_at ASC, p.id ASC
+		LIMIT $4
+	`
+	rows, err := r.conn.Query(ctx, query, followerID, after.CreatedAt, after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectReposts(rows)
+}
+
+func collectReposts(rows pgx.Rows) ([]*Repost, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Repost, error) {
+		var (
+			repost   Repost
+			original messageRow
+		)
+		targets := append([]any{&repost.id, &repost.userID, &repost.createdAt}, original.targets()...)
+		if err := row.Scan(targets...); err != nil {
+			return nil, err
+		}
+		repost.message = original.message()
+		return &repost, nil
+	})
+}
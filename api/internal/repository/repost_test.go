@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetRepostsPagesByRepostTime(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	first := saveTestMessage(t, repo, NewMessage("user-1", "first"))
+	second := saveTestMessage(t, repo, NewMessage("user-1", "second"))
+	deleted := saveTestMessage(t, repo, NewMessage("user-1", "deleted"))
+	for _, msg := range []*Message{second, first, deleted} {
+		if err := repo.Repost(ctx, msg.ID(), "user-2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.ApplyChange(ctx, NewMessageDeletion(deleted.ID(), "user-1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Follow(ctx, "user-3", "user-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	newest, err := repo.GetRepostsBefore(ctx, Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newest) != 2 || newest[0].Message().ID() != first.ID() || newest[1].Message().ID() != second.ID() {
+		t.Fatalf("got %d reposts, want the reposts of first and second, newest first", len(newest))
+	}
+
+	after, err := repo.GetHomeRepostsAfter(ctx, "user-3", newest[1].Cursor(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != 1 || after[0].ID() != newest[0].ID() {
+		t.Fatalf("got %d reposts after the oldest, want the newest", len(after))
+	}
+
+	none, err := repo.GetHomeRepostsBefore(ctx, "user-2", Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("got %d reposts for a user following nobody, want none", len(none))
+	}
+}
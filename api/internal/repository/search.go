@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"feed-api/internal/search"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// likeEscaper escapes the wildcards of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchMessages returns up to limit messages matching query strictly older
+// than the cursor, newest first, leaving out deleted messages. Terms and
+// phrases are matched as case-insensitive substrings, which the trigram index
+// on content serves.
+func (r *CockroachRepo) SearchMessages(ctx context.Context, query search.Query, before Cursor, limit int) ([]*Message, error) {
+	var (
+		conditions = []string{"deleted_at IS NULL"}
+		args       []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	for _, text := range slices.Concat(query.Terms, query.Phrases) {
+		conditions = append(conditions, "content ILIKE "+arg("%"+likeEscaper.Replace(text)+"%"))
+	}
+	if len(query.From) > 0 {
+		conditions = append(conditions, "user_id = ANY("+arg(query.From)+"::STRING[])")
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(query.Since))
+	}
+	if !query.Until.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(query.Until))
+	}
+	if !before.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < (%s, %s::UUID)", arg(before.CreatedAt), arg(before.ID)))
+	}
+
+	sql := `
+		SELECT id, user_id, content, created_at, edited_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count FROM messages
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + arg(limit)
+	rows, err := r.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectMessages(rows)
+}
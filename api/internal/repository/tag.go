@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"feed-api/internal/tags"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// replaceTags re-indexes a message whose content changed.
+func replaceTags(ctx context.Context, tx pgx.Tx, messageID, authorID string, createdAt time.Time, set tags.Set) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM message_hashtags WHERE message_id = $1`, messageID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM message_mentions WHERE message_id = $1`, messageID); err != nil {
+		return err
+	}
+	return insertTags(ctx, tx, messageID, authorID, createdAt, set)
+}
+
+// insertTags indexes a message under each of its hashtags and mentioned users.
+// Inserting the same tags twice is a no-op.
+func insertTags(ctx context.Context, tx pgx.Tx, messageID, authorID string, createdAt time.Time, set tags.Set) error {
+	query := `
+		INSERT INTO message_hashtags (tag, created_at, message_id)
+		SELECT tag, $2, $3 FROM unnest($1::STRING[]) AS tag
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, query, set.Hashtags, createdAt, messageID); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO message_mentions (user_id, created_at, message_id, author_id)
+		SELECT user_id, $2, $3, $4 FROM unnest($1::STRING[]) AS user_id
+		ON CONFLICT DO NOTHING
+	`
+	_, err := tx.Exec(ctx, query, set.Mentions, createdAt, messageID, authorID)
+	return err
+}
+
+// GetTaggedMessagesBefore is GetMessagesBefore restricted to messages with the
+// given normalized hashtag.
+func (r *CockroachRepo) GetTaggedMessagesBefore(ctx context.Context, tag string, before Cursor, limit int) ([]*Message, error) {
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	if before.IsZero() {
+		query := `
+			SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM message_hashtags AS t
+			JOIN messages AS m ON m.id = t.message_id
+			WHERE t.tag = $1 AND m.deleted_at IS NULL
+			ORDER BY t.created_at DESC, t.message_id DESC
+			LIMIT $2
+		`
+		rows, err = r.conn.Query(ctx, query, tag, limit)
+	} else {
+		query := `
+			SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM message_hashtags AS t
+			JOIN messages AS m ON m.id = t.message_id
+			WHERE t.tag = $1 AND m.deleted_at IS NULL AND (t.created_at, t.message_id) < ($2, $3::UUID)
+			ORDER BY t.created_at DESC, t.message_id DESC
+			LIMIT $4
+		`
+		rows, err = r.conn.Query(ctx, query, tag, before.CreatedAt, before.ID, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return collectMessages(rows)
+}
+
+// GetTaggedMessagesAfter is GetMessagesAfter restricted to messages with the
+// given normalized hashtag.
+func (r *CockroachRepo) GetTaggedMessagesAfter(ctx context.Context, tag string, after Cursor, limit int) ([]*Message, error) {
+	query := `
+		SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM message_hashtags AS t
+		JOIN messages AS m ON m.id = t.message_id
+		WHERE t.tag = $1 AND m.deleted_at IS NULL AND (t.created_at, t.message_id) > ($2, $3::UUID)
+		ORDER BY t.created_at ASC, t.message_id ASC
+		LIMIT $4
+	`
+	rows, err := r.conn.Query(ctx, query, tag, after.CreatedAt, after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectMessages(rows)
+}
+
+// GetMentionsBefore is GetMessagesBefore restricted to messages mentioning
+// userID.
+func (r *CockroachRepo) GetMentionsBefore(ctx context.Context, userID string, before Cursor, limit int) ([]*Message, error) {
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	if before.IsZero() {
+		query := `
+			SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM message_mentions AS t
+			JOIN messages AS m ON m.id = t.message_id
+			WHERE t.user_id = $1 AND m.deleted_at IS NULL
+			ORDER BY t.created_at DESC, t.message_id DESC
+			LIMIT $2
+		`
+		rows, err = r.conn.Query(ctx, query, userID, limit)
+	} else {
+		query := `
+			SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM message_mentions AS t
+			JOIN messages AS m ON m.id = t.message_id
+			WHERE t.user_id = $1 AND m.deleted_at IS NULL AND (t.created_at, t.message_id) < ($2, $3::UUID)
+			ORDER BY t.created_at DESC, t.message_id DESC
+			LIMIT $4
+		`
+		rows, err = r.conn.Query(ctx, query, userID, before.CreatedAt, before.ID, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return collectMessages(rows)
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestApplyChangeReindexesTagsOfEditedMessages(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	msg := saveTestMessage(t, repo, NewMessage("user-1", "Learning #go with @user-2"))
+
+	if err := repo.ApplyChange(ctx, NewMessageEdit(msg.ID(), "user-1", "Switching to #rust, thanks @user-3")); err != nil {
+		t.Fatal(err)
+	}
+
+	for tag, want := range map[string]int{"go": 0, "rust": 1} {
+		messages, err := repo.GetTaggedMessagesBefore(ctx, tag, Cursor{}, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != want {
+			t.Errorf("got %d messages tagged %s, want %d", len(messages), tag, want)
+		}
+	}
+
+	rows, err := repo.conn.Query(ctx, `SELECT user_id FROM message_mentions WHERE message_id = $1`, msg.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mentions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mentions) != 1 || mentions[0] != "user-3" {
+		t.Errorf("got mentions %v, want [user-3]", mentions)
+	}
+}
+
+func TestSaveMessagesIndexesHashtagsAndMentions(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	tagged := NewMessage("user-1", "Learning #Go, thanks @user-2")
+	mentioned := NewMessage("user-3", "@user-2 did you see this?")
+	mentioned.createdAt = tagged.createdAt.Add(time.Millisecond)
+	untagged := NewMessage("user-1", "nothing to index")
+	if err := repo.SaveMessages(ctx, []*Message{tagged, mentioned, untagged}); err != nil {
+		t.Fatal(err)
+	}
+	// A redelivered message is not indexed twice.
+	saveTestMessage(t, repo, tagged)
+
+	messages, err := repo.GetTaggedMessagesBefore(ctx, "go", Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].ID() != tagged.ID() {
+		t.Fatalf("got %d messages tagged go, want the tagged one", len(messages))
+	}
+
+	mentions, err := repo.GetMentionsBefore(ctx, "user-2", Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mentions) != 2 || mentions[0].ID() != mentioned.ID() || mentions[1].ID() != tagged.ID() {
+		t.Fatalf("got %d mentions of user-2, want both mentioning messages newest first", len(mentions))
+	}
+	page, err := repo.GetMentionsBefore(ctx, "user-2", mentions[0].Cursor(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].ID() != tagged.ID() {
+		t.Fatalf("got %d mentions before the newest, want the older one", len(page))
+	}
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetThread returns up to limit messages of the conversation the message with
+// the given id belongs to, strictly after the cursor, oldest first. A message
+// always comes after the one it replies to. Deleted messages are kept as
+// placeholders without content so their replies stay attached. It returns
+// ErrNotFound for unknown ids.
+func (r *CockroachRepo) GetThread(ctx context.Context, id string, after Cursor, limit int) ([]*Message, error) {
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	if after.IsZero() {
+		query := `
+			SELECT id, user_id, content, created_at, edited_at, deleted_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count
+			FROM messages
+			WHERE conversation_id = (SELECT conversation_id FROM messages WHERE id = $1)
+			ORDER BY created_at ASC, id ASC
+			LIMIT $2
+		`
+		rows, err = r.conn.Query(ctx, query, id, limit)
+	} else {
+		query := `
+			SELECT id, user_id, content, created_at, edited_at, deleted_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count
+			FROM messages
+			WHERE conversation_id = (SELECT conversation_id FROM messages WHERE id = $1)
+				AND (created_at, id) > ($2, $3::UUID)
+			ORDER BY created_at ASC, id ASC
+			LIMIT $4
+		`
+		rows, err = r.conn.Query(ctx, query, id, after.CreatedAt, after.ID, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Message, error) {
+		return scanThreadMessage(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The conversation holds at least the message itself.
+	if after.IsZero() && len(messages) == 0 {
+		return nil, ErrNotFound
+	}
+	return messages, nil
+}
+
+// scanThreadMessage scans a message including its deletion time, leaving out
+// the content of deleted messages.
+func scanThreadMessage(row pgx.Row) (*Message, error) {
+	var (
+		msg            Message
+		editedAt       *time.Time
+		deletedAt      *time.Time
+		inReplyTo      *string
+		conversationID *string
+	)
+	err := row.Scan(&msg.id, &msg.userID, &msg.content, &msg.createdAt, &editedAt, &deletedAt,
+		&inReplyTo, &conversationID, &msg.depth, &msg.replyCount, &msg.likeCount, &msg.repostCount)
+	if err != nil {
+		return nil, err
+	}
+	if editedAt != nil {
+		msg.editedAt = *editedAt
+	}
+	if deletedAt != nil {
+		msg.deletedAt = *deletedAt
+		msg.content = ""
+	}
+	if inReplyTo != nil {
+		msg.inReplyTo = *inReplyTo
+	}
+	if conversationID != nil {
+		msg.conversationID = *conversationID
+	}
+	return &msg, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AddTimelineEntries inserts msg into the home timeline of every given follower.
+func (r *CockroachRepo) AddTimelineEntries(ctx context.Context, followerIDs []string, msg *Message) error {
+	query := `
+		INSERT INTO timeline_entries (user_id, created_at, message_id, author_id)
+		SELECT follower_id, $2, $3, $4 FROM unnest($1::STRING[]) AS follower_id
+		ON CONFLICT DO NOTHING
+	`
+	_, err := r.conn.Exec(ctx, query, followerIDs, msg.createdAt, msg.id, msg.userID)
+	return err
+}
+
+// MarkFannedOut records that msg is in the home timeline of every follower,
+// so home timelines stop merging it at read time.
+func (r *CockroachRepo) MarkFannedOut(ctx context.Context, msg *Message) error {
+	query := `UPDATE messages SET fanned_out = true WHERE id = $1`
+	_, err := r.conn.Exec(ctx, query, msg.id)
+	return err
+}
+
+// Home timelines are read from timeline_entries, merged with the messages of
+// followed accounts that were not fanned out on write: those of authors with
+// too large an audience, and those whose fan-out has not finished yet. Which
+// messages were fanned out is stored per message, so an author's follower
+// count changing later does not move their earlier messages. Entries are only
+// read while the follow still exists: a fan-out racing with an unfollow can
+// write entries after Unfollow removed them.
+
+// GetHomeTimelineBefore is GetMessagesBefore restricted to authors followerID follows.
+func (r *CockroachRepo) GetHomeTimelineBefore(ctx context.Context, followerID string, before Cursor, limit int) ([]*Message, error) {
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	if before.IsZero() {
+		query := `
+			SELECT id, user_id, content, created_at, edited_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count FROM (
+				SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM timeline_entries AS t
+				JOIN messages AS m ON m.id = t.message_id
+				JOIN follows AS f ON f.follower_id = t.user_id AND f.followee_id = t.author_id
+				WHERE t.user_id = $1 AND m.deleted_at IS NULL
+				UNION
+				SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM follows AS f
+				JOIN messages AS m ON m.user_id = f.followee_id
+				WHERE f.follower_id = $1 AND NOT m.fanned_out AND m.deleted_at IS NULL
+			)
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		`
+		rows, err = r.conn.Query(ctx, query, followerID, limit)
+	} else {
+		query := `
+			SELECT id, user_id, content, created_at, edited_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count FROM (
+				SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM timeline_entries AS t
+				JOIN messages AS m ON m.id = t.message_id
+				JOIN follows AS f ON f.follower_id = t.user_id AND f.followee_id = t.author_id
+				WHERE t.user_id = $1 AND m.deleted_at IS NULL AND (t.created_at, t.message_id) < ($2, $3::UUID)
+				UNION
+				SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM follows AS f
+				JOIN messages AS m ON m.user_id = f.followee_id
+				WHERE f.follower_id = $1 AND NOT m.fanned_out AND m.deleted_at IS NULL AND (m.created_at, m.id) < ($2, $3::UUID)
+			)
+			ORDER BY created_at DESC, id DESC
+			LIMIT $4
+		`
+		rows, err = r.conn.Query(ctx, query, followerID, before.CreatedAt, before.ID, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return collectMessages(rows)
+}
+
+// GetHomeTimelineAfter is GetMessagesAfter restricted to authors followerID follows.
+func (r *CockroachRepo) GetHomeTimelineAfter(ctx context.Context, followerID string, after Cursor, limit int) ([]*Message, error) {
+	query := `
+		SELECT id, user_id, content, created_at, edited_at, in_reply_to, conversation_id, depth, reply_count, like_count, repost_count FROM (
+			SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM timeline_entries AS t
+			JOIN messages AS m ON m.id = t.message_id
+			JOIN follows AS f ON f.follower_id = t.user_id AND f.followee_id = t.author_id
+			WHERE t.user_id = $1 AND m.deleted_at IS NULL AND (t.created_at, t.message_id) > ($2, $3::UUID)
+			UNION
+			SELECT m.id, m.user_id, m.content, m.created_at, m.edited_at, m.in_reply_to, m.conversation_id, m.depth, m.reply_count, m.like_count, m.repost_count FROM follows AS f
+			JOIN messages AS m ON m.user_id = f.followee_id
+			WHERE f.follower_id = $1 AND NOT m.fanned_out AND m.deleted_at IS NULL AND (m.created_at, m.id) > ($2, $3::UUID)
+		)
+		ORDER BY created_at ASC, id ASC
+		LIMIT $4
+	`
+	rows, err := r.conn.Query(ctx, query, followerID, after.CreatedAt, after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectMessages(rows)
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHomeTimelineKeepsMessagesWhenFollowerCountChanges(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	if err := repo.Follow(ctx, "reader", "author"); err != nil {
+		t.Fatal(err)
+	}
+	// fannedOut went through the worker's fan-out, pending has not yet.
+	fannedOut := saveTestMessage(t, repo, NewMessage("author", "fanned out"))
+	if err := repo.AddTimelineEntries(ctx, []string{"reader"}, fannedOut); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkFannedOut(ctx, fannedOut); err != nil {
+		t.Fatal(err)
+	}
+	pending := saveTestMessage(t, repo, NewMessage("author", "pending"))
+
+	for _, followers := range []int{1, DefaultFanOutThreshold + 1, 1} {
+		if _, err := repo.conn.Exec(ctx, `UPDATE follower_counts SET followers = $2 WHERE user_id = $1`, "author", followers); err != nil {
+			t.Fatal(err)
+		}
+
+		messages, err := repo.GetHomeTimelineBefore(ctx, "reader", Cursor{}, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != 2 || messages[0].ID() != pending.ID() || messages[1].ID() != fannedOut.ID() {
+			t.Fatalf("with %d followers got %d messages, want pending and fanned out once each", followers, len(messages))
+		}
+
+		after, err := repo.GetHomeTimelineAfter(ctx, "reader", fannedOut.Cursor(), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(after) != 1 || after[0].ID() != pending.ID() {
+			t.Fatalf("with %d followers got %d messages after the first, want pending", followers, len(after))
+		}
+	}
+}
+
+func TestHomeTimelineHidesEntriesOfUnfollowedAuthors(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	if err := repo.Follow(ctx, "reader", "author"); err != nil {
+		t.Fatal(err)
+	}
+	msg := saveTestMessage(t, repo, NewMessage("author", "hello"))
+	if err := repo.Unfollow(ctx, "reader", "author"); err != nil {
+		t.Fatal(err)
+	}
+	// A fan-out that read the followers before the unfollow writes its
+	// entries after Unfollow removed them.
+	if err := repo.AddTimelineEntries(ctx, []string{"reader"}, msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkFannedOut(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := repo.GetHomeTimelineBefore(ctx, "reader", Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlier := Cursor{CreatedAt: msg.CreatedAt().Add(-time.Second), ID: msg.ID()}
+	after, err := repo.GetHomeTimelineAfter(ctx, "reader", earlier, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 0 || len(after) != 0 {
+		t.Fatalf("got %d and %d messages, want none from an unfollowed author", len(before), len(after))
+	}
+}
@@ -0,0 +1,125 @@
+// Package search parses the query language of message search.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const dateLayout = "2006-01-02"
+
+var ErrEmptyQuery = errors.New("search query is empty")
+
+// Query is a parsed search. A message matches if its content contains every
+// term and phrase, ignoring case, it was written by one of From (if any), and
+// it was created within [Since, Until).
+type Query struct {
+	Terms   []string
+	Phrases []string
+	From    []string
+	Since   time.Time
+	Until   time.Time
+}
+
+// Parse parses q. Words are matched anywhere in the content, "quoted text"
+// as a whole, and the operators
+//
+//	from:user-1        messages by user-1; repeat for any of several authors
+//	since:2024-01-01   messages created on or after the day (UTC)
+//	until:2024-01-31   messages created on or before the day (UTC)
+//
+// restrict the results. Dates may also be RFC 3339 timestamps, in which case
+// until is exclusive. Unknown operators are searched for as words.
+func Parse(q string) (Query, error) {
+	var query Query
+	runes := []rune(q)
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			end := indexRune(runes, i+1, '"')
+			if end < 0 {
+				return Query{}, errors.New("unterminated quote")
+			}
+			if phrase := strings.Join(strings.Fields(string(runes[i+1:end])), " "); phrase != "" {
+				query.Phrases = append(query.Phrases, phrase)
+			}
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			if err := query.add(string(runes[i:end])); err != nil {
+				return Query{}, err
+			}
+			i = end
+		}
+	}
+
+	if query.IsZero() {
+		return Query{}, ErrEmptyQuery
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && !query.Since.Before(query.Until) {
+		return Query{}, errors.New("since must be before until")
+	}
+	return query, nil
+}
+
+// IsZero reports whether the query matches every message.
+func (q Query) IsZero() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0 && len(q.From) == 0 && q.Since.IsZero() && q.Until.IsZero()
+}
+
+// add adds a word or operator.
+func (q *Query) add(word string) error {
+	key, value, ok := strings.Cut(word, ":")
+	if !ok {
+		q.Terms = append(q.Terms, word)
+		return nil
+	}
+
+	var err error
+	switch strings.ToLower(key) {
+	case "from":
+		if value == "" {
+			return errors.New("from: needs a user id")
+		}
+		q.From = append(q.From, value)
+	case "since":
+		q.Since, err = parseTime(key, value, false)
+	case "until":
+		q.Until, err = parseTime(key, value, true)
+	default:
+		q.Terms = append(q.Terms, word)
+	}
+	return err
+}
+
+// parseTime parses a date or timestamp. A date given as until stands for the
+// end of that day.
+func parseTime(key, value string, until bool) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		if until {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("%s: must be a date (YYYY-MM-DD) or RFC 3339 timestamp", key)
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,75 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		q    string
+		want Query
+	}{
+		{
+			q:    "kafka  outbox",
+			want: Query{Terms: []string{"kafka", "outbox"}},
+		},
+		{
+			q:    `"exactly  once" delivery "" "at least once"`,
+			want: Query{Terms: []string{"delivery"}, Phrases: []string{"exactly once", "at least once"}},
+		},
+		{
+			q:    "from:user-1 FROM:user-2 hello",
+			want: Query{Terms: []string{"hello"}, From: []string{"user-1", "user-2"}},
+		},
+		{
+			q: "since:2024-01-01 until:2024-01-31",
+			want: Query{
+				Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				Until: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			q: "since:2024-01-01T12:00:00+02:00 go",
+			want: Query{
+				Terms: []string{"go"},
+				Since: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			q:    "https://example.com 日本語",
+			want: Query{Terms: []string{"https://example.com", "日本語"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.q)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.q, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsInvalidQueries(t *testing.T) {
+	for _, q := range []string{
+		`"unterminated phrase`,
+		"from:",
+		"since:yesterday",
+		"since:2024-02-01 until:2024-01-01",
+	} {
+		if _, err := Parse(q); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", q)
+		}
+	}
+
+	for _, q := range []string{"", "   ", `""`} {
+		if _, err := Parse(q); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("Parse(%q) = %v, want ErrEmptyQuery", q, err)
+		}
+	}
+}
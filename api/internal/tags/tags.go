@@ -0,0 +1,103 @@
+// Package tags finds the hashtags and mentions in message content.
+package tags
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Set holds the hashtags and mentions of a text, each once, in the order they
+// first appear.
+type Set struct {
+	Hashtags []string
+	Mentions []string
+}
+
+func (s Set) IsZero() bool {
+	return len(s.Hashtags) == 0 && len(s.Mentions) == 0
+}
+
+// Extract finds the #hashtags and @mentions in content, in any script. A
+// marker only starts a tag at the beginning of the text or after a character
+// that cannot be part of one, so e-mail addresses and fragments like "a#b" are
+// skipped. Hashtags are normalized with NormalizeHashtag; mentions are kept as
+// written, since user ids are case-sensitive.
+func Extract(content string) Set {
+	var (
+		set      Set
+		hashtags = make(map[string]struct{})
+		mentions = make(map[string]struct{})
+		runes    = []rune(content)
+	)
+	for i, marker := range runes {
+		if !isMarker(marker) || i > 0 && (isHashtagRune(runes[i-1]) || isMarker(runes[i-1])) {
+			continue
+		}
+
+		j := i + 1
+		if isHashtagMarker(marker) {
+			for j < len(runes) && isHashtagRune(runes[j]) {
+				j++
+			}
+			if tag, ok := NormalizeHashtag(string(runes[i+1 : j])); ok {
+				set.Hashtags = appendNew(set.Hashtags, hashtags, tag)
+			}
+			continue
+		}
+
+		for j < len(runes) && isMentionRune(runes[j]) {
+			j++
+		}
+		// Punctuation ending a sentence is not part of the user id.
+		if mention := strings.TrimRight(string(runes[i+1:j]), ".-"); mention != "" {
+			set.Mentions = appendNew(set.Mentions, mentions, mention)
+		}
+	}
+	return set
+}
+
+// NormalizeHashtag lowercases tag and strips a leading marker. It reports
+// false if tag is no valid hashtag: hashtags are made of letters, digits,
+// marks and underscores, and are not only digits.
+func NormalizeHashtag(tag string) (string, bool) {
+	tag = strings.TrimPrefix(tag, "#")
+	tag = strings.TrimPrefix(tag, "＃")
+	hasLetter := false
+	for _, r := range tag {
+		if !isHashtagRune(r) {
+			return "", false
+		}
+		if unicode.IsLetter(r) || unicode.IsMark(r) {
+			hasLetter = true
+		}
+	}
+	if !hasLetter {
+		return "", false
+	}
+	return strings.ToLower(tag), true
+}
+
+// appendNew appends tag unless it was seen before.
+func appendNew(tags []string, seen map[string]struct{}, tag string) []string {
+	if _, ok := seen[tag]; ok {
+		return tags
+	}
+	seen[tag] = struct{}{}
+	return append(tags, tag)
+}
+
+func isMarker(r rune) bool {
+	return isHashtagMarker(r) || r == '@' || r == '＠'
+}
+
+func isHashtagMarker(r rune) bool {
+	return r == '#' || r == '＃'
+}
+
+func isHashtagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_'
+}
+
+func isMentionRune(r rune) bool {
+	return isHashtagRune(r) || r == '-' || r == '.'
+}
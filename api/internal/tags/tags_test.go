@@ -0,0 +1,71 @@
+package tags
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		content  string
+		hashtags []string
+		mentions []string
+	}{
+		{
+			content:  "Loving #Go and #go, thanks @user-1.",
+			hashtags: []string{"go"},
+			mentions: []string{"user-1"},
+		},
+		{
+			content:  "#café #日本語 #Привет #straße",
+			hashtags: []string{"café", "日本語", "привет", "straße"},
+		},
+		{
+			content:  "＃全角 and ＠user_2's reply",
+			hashtags: []string{"全角"},
+			mentions: []string{"user_2"},
+		},
+		{
+			content: "mail a@b.com, see page#anchor, ## and @ alone",
+		},
+		{
+			content:  "#123 is no tag but #2024年 is; #go#rust only counts once",
+			hashtags: []string{"2024年", "go"},
+		},
+		{
+			content:  "(#go) [@user-1] @user.name @user-1",
+			hashtags: []string{"go"},
+			mentions: []string{"user-1", "user.name"},
+		},
+	}
+	for _, tt := range tests {
+		set := Extract(tt.content)
+		if !slices.Equal(set.Hashtags, tt.hashtags) {
+			t.Errorf("%q: got hashtags %q, want %q", tt.content, set.Hashtags, tt.hashtags)
+		}
+		if !slices.Equal(set.Mentions, tt.mentions) {
+			t.Errorf("%q: got mentions %q, want %q", tt.content, set.Mentions, tt.mentions)
+		}
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		ok   bool
+	}{
+		{tag: "Go", want: "go", ok: true},
+		{tag: "#Go", want: "go", ok: true},
+		{tag: "ÉTÉ", want: "été", ok: true},
+		{tag: "go-lang"},
+		{tag: "2024"},
+		{tag: ""},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeHashtag(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeHashtag(%q) = %q, %v, want %q, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
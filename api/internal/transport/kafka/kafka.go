@@ -0,0 +1,411 @@
+// Package kafka is the transport driver backed by a Kafka cluster.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"feed-api/internal/transport"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+type Transport struct {
+	brokers []string
+}
+
+func New(brokers []string) *Transport {
+	return &Transport{
+		brokers: brokers,
+	}
+}
+
+func (t *Transport) NewPublisher(config transport.PublisherConfig) transport.Publisher {
+	writer := &kafkago.Writer{
+		Addr:     kafkago.TCP(t.brokers...),
+		Balancer: &kafkago.LeastBytes{},
+		// Retry and dead-letter topic names depend on configuration.
+		AllowAutoTopicCreation: true,
+	}
+	if config.Partitioning == transport.PartitionByKey {
+		writer.Balancer = &kafkago.Hash{}
+	}
+	switch config.Acks {
+	case transport.AckAll:
+		writer.RequiredAcks = kafkago.RequireAll
+	case transport.AckLeader:
+		writer.RequiredAcks = kafkago.RequireOne
+	case transport.AckNone:
+		writer.RequiredAcks = kafkago.RequireNone
+	}
+	writer.Async = config.Async
+	if completion := config.Completion; completion != nil {
+		writer.Completion = func(messages []kafkago.Message, err error) {
+			msgs := make([]transport.Message, 0, len(messages))
+			for _, msg := range messages {
+				msgs = append(msgs, fromKafka(msg))
+			}
+			completion(msgs, err)
+		}
+	}
+	return &publisher{
+		writer: writer,
+	}
+}
+
+func (t *Transport) Subscribe(config transport.SubscriptionConfig) transport.Subscription {
+	startOffset := kafkago.FirstOffset
+	if config.StartOffset == transport.StartLatest {
+		startOffset = kafkago.LastOffset
+	}
+	if config.GroupID == "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &partitionSubscription{
+			transport:   t,
+			topic:       config.Topic,
+			startOffset: startOffset,
+			messages:    make(chan kafkago.Message),
+			ctx:         ctx,
+			cancel:      cancel,
+		}
+	}
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:     t.brokers,
+		Topic:       config.Topic,
+		GroupID:     config.GroupID,
+		StartOffset: startOffset,
+	})
+	return &subscription{
+		reader: reader,
+	}
+}
+
+func (t *Transport) Partitions(ctx context.Context, topic string) ([]int, error) {
+	conn, err := kafkago.DialContext(ctx, "tcp", t.brokers[0])
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(partitions))
+	for _, p := range partitions {
+		ids = append(ids, p.ID)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (t *Transport) Offsets(ctx context.Context, topic string, partition int) (first, last int64, err error) {
+	conn, err := kafkago.DialLeader(ctx, "tcp", t.brokers[0], topic, partition)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+	return conn.ReadOffsets()
+}
+
+func (t *Transport) Read(ctx context.Context, topic string, partition int, from, to int64, fn func(msg transport.Message) bool) error {
+	if from >= to {
+		return nil
+	}
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:   t.brokers,
+		Topic:     topic,
+		Partition: partition,
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(from); err != nil {
+		return err
+	}
+	for offset := from; offset < to; {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return err
+		}
+		// Compacted topics may skip offsets.
+		if msg.Offset >= to {
+			return nil
+		}
+		offset = msg.Offset + 1
+		if !fn(fromKafka(msg)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (t *Transport) CommittedOffsets(ctx context.Context, groupID, topic string) (map[int]int64, error) {
+	partitions, err := t.Partitions(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client().OffsetFetch(ctx, &kafkago.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  map[string][]int{topic: partitions},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	offsets := make(map[int]int64)
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return nil, p.Error
+		}
+		// Partitions the group never committed report -1.
+		if p.CommittedOffset >= 0 {
+			offsets[p.Partition] = p.CommittedOffset
+		}
+	}
+	return offsets, nil
+}
+
+// CommitOffsets commits outside any group generation, which the broker only
+// accepts while the group has no active members.
+func (t *Transport) CommitOffsets(ctx context.Context, groupID, topic string, offsets map[int]int64) error {
+	commits := make([]kafkago.OffsetCommit, 0, len(offsets))
+	for partition, offset := range offsets {
+		commits = append(commits, kafkago.OffsetCommit{Partition: partition, Offset: offset})
+	}
+	resp, err := t.client().OffsetCommit(ctx, &kafkago.OffsetCommitRequest{
+		GroupID:      groupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafkago.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return p.Error
+		}
+	}
+	return nil
+}
+
+func (t *Transport) client() *kafkago.Client {
+	return &kafkago.Client{Addr: kafkago.TCP(t.brokers...)}
+}
+
+type publisher struct {
+	writer *kafkago.Writer
+}
+
+func (p *publisher) Publish(ctx context.Context, msgs ...transport.Message) error {
+	messages := make([]kafkago.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		messages = append(messages, toKafka(msg))
+	}
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+func (p *publisher) Close() error {
+	return p.writer.Close()
+}
+
+type subscription struct {
+	reader *kafkago.Reader
+}
+
+func (s *subscription) Fetch(ctx context.Context) (transport.Message, error) {
+	for {
+		msg, err := s.reader.FetchMessage(ctx)
+		if err != nil {
+			var kafkaErr kafkago.Error
+			if errors.As(err, &kafkaErr) && errors.Is(kafkaErr, kafkago.NotCoordinatorForGroup) {
+				log.Println("Kafka reader not coordinator for group, retrying...")
+				if sleepErr := sleep(ctx, 3*time.Second); sleepErr != nil {
+					return transport.Message{}, sleepErr
+				}
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return transport.Message{}, transport.ErrClosed
+			}
+			return transport.Message{}, err
+		}
+		return fromKafka(msg), nil
+	}
+}
+
+func (s *subscription) Commit(ctx context.Context, msgs ...transport.Message) error {
+	messages := make([]kafkago.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		messages = append(messages, kafkago.Message{
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+		})
+	}
+	return s.reader.CommitMessages(ctx, messages...)
+}
+
+func (s *subscription) Close() error {
+	return s.reader.Close()
+}
+
+// partitionSubscription reads every partition of a topic without a consumer
+// group, so it leaves nothing behind on the broker. The partitions are looked
+// up on the first Fetch; partitions added later are not read.
+type partitionSubscription struct {
+	transport   *Transport
+	topic       string
+	startOffset int64
+	messages    chan kafkago.Message
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	mu      sync.Mutex
+	readers []*kafkago.Reader
+	wg      sync.WaitGroup
+}
+
+func (s *partitionSubscription) Fetch(ctx context.Context) (transport.Message, error) {
+	if err := s.start(ctx); err != nil {
+		if errors.Is(err, transport.ErrClosed) {
+			return transport.Message{}, err
+		}
+		// The topic may not exist yet; don't let the caller spin.
+		if sleepErr := sleep(ctx, 3*time.Second); sleepErr != nil {
+			return transport.Message{}, sleepErr
+		}
+		return transport.Message{}, err
+	}
+	select {
+	case msg := <-s.messages:
+		return fromKafka(msg), nil
+	case <-s.ctx.Done():
+		return transport.Message{}, transport.ErrClosed
+	case <-ctx.Done():
+		return transport.Message{}, ctx.Err()
+	}
+}
+
+func (s *partitionSubscription) start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return transport.ErrClosed
+	}
+	if s.readers != nil {
+		return nil
+	}
+	partitions, err := s.transport.Partitions(ctx, s.topic)
+	if err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		return fmt.Errorf("topic %s has no partitions", s.topic)
+	}
+	for _, partition := range partitions {
+		reader := kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers:   s.transport.brokers,
+			Topic:     s.topic,
+			Partition: partition,
+		})
+		if err = reader.SetOffset(s.startOffset); err != nil {
+			reader.Close()
+			return err
+		}
+		s.readers = append(s.readers, reader)
+		s.wg.Add(1)
+		go s.read(reader)
+	}
+	return nil
+}
+
+func (s *partitionSubscription) read(reader *kafkago.Reader) {
+	defer s.wg.Done()
+	for {
+		// Without a group ReadMessage commits nothing.
+		msg, err := reader.ReadMessage(s.ctx)
+		if err != nil {
+			if s.ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return
+			}
+			log.Println("Error reading partition:", err)
+			if sleep(s.ctx, time.Second) != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case s.messages <- msg:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// Commit is a no-op: without a group there are no offsets to keep.
+func (s *partitionSubscription) Commit(ctx context.Context, msgs ...transport.Message) error {
+	return nil
+}
+
+func (s *partitionSubscription) Close() error {
+	s.mu.Lock()
+	s.cancel()
+	readers := s.readers
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	var errs []error
+	for _, reader := range readers {
+		errs = append(errs, reader.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func toKafka(msg transport.Message) kafkago.Message {
+	headers := make([]kafkago.Header, 0, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers = append(headers, kafkago.Header{Key: h.Key, Value: h.Value})
+	}
+	return kafkago.Message{
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+}
+
+func fromKafka(msg kafkago.Message) transport.Message {
+	headers := make([]transport.Header, 0, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers = append(headers, transport.Header{Key: h.Key, Value: h.Value})
+	}
+	return transport.Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   headers,
+		Time:      msg.Time,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,385 @@
+// Package memory is an in-process transport driver. Topics are partitioned
+// logs kept in memory for the life of the process, with consumer groups that
+// share partitions and commit offsets like Kafka's. It lets the whole API run
+// in a single process without a broker.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"feed-api/internal/transport"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+const DefaultPartitions = 3
+
+var errNoTopic = errors.New("memory transport: message has no topic")
+
+type Transport struct {
+	mu         sync.Mutex
+	partitions int
+	topics     map[string]*topic
+	// changed is closed and replaced whenever a message is published or a
+	// group's membership changes, waking every blocked Fetch.
+	changed chan struct{}
+}
+
+// New creates a transport whose topics have the given number of partitions.
+func New(partitions int) *Transport {
+	if partitions < 1 {
+		partitions = DefaultPartitions
+	}
+	return &Transport{
+		partitions: partitions,
+		topics:     make(map[string]*topic),
+		changed:    make(chan struct{}),
+	}
+}
+
+type topic struct {
+	logs   [][]transport.Message
+	groups map[string]*group
+	next   int
+}
+
+type group struct {
+	// committed holds the offset of the next message to consume, for each
+	// partition the group has committed.
+	committed  map[int]int64
+	members    []*subscription
+	generation int
+}
+
+func (t *Transport) NewPublisher(config transport.PublisherConfig) transport.Publisher {
+	return &publisher{
+		transport:    t,
+		partitioning: config.Partitioning,
+		completion:   config.Completion,
+	}
+}
+
+func (t *Transport) Subscribe(config transport.SubscriptionConfig) transport.Subscription {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tp := t.topic(config.Topic)
+	s := &subscription{
+		transport: t,
+		config:    config,
+		positions: make(map[int]int64),
+	}
+	if config.GroupID == "" {
+		for p := range tp.logs {
+			s.assigned = append(s.assigned, p)
+			if config.StartOffset == transport.StartLatest {
+				s.positions[p] = int64(len(tp.logs[p]))
+			}
+		}
+		return s
+	}
+
+	g, ok := tp.groups[config.GroupID]
+	if !ok {
+		g = &group{committed: make(map[int]int64)}
+		tp.groups[config.GroupID] = g
+	}
+	s.group = g
+	s.generation = -1
+	g.members = append(g.members, s)
+	g.generation++
+	t.notify()
+	return s
+}
+
+func (t *Transport) Partitions(ctx context.Context, topic string) ([]int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := make([]int, 0, t.partitions)
+	for p := range t.topic(topic).logs {
+		ids = append(ids, p)
+	}
+	return ids, nil
+}
+
+func (t *Transport) Offsets(ctx context.Context, topic string, partition int) (first, last int64, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	logs := t.topic(topic).logs
+	if partition < 0 || partition >= len(logs) {
+		return 0, 0, nil
+	}
+	return 0, int64(len(logs[partition])), nil
+}
+
+func (t *Transport) Read(ctx context.Context, topic string, partition int, from, to int64, fn func(msg transport.Message) bool) error {
+	t.mu.Lock()
+	logs := t.topic(topic).logs
+	var messages []transport.Message
+	if partition >= 0 && partition < len(logs) {
+		log := logs[partition]
+		from = max(from, 0)
+		to = min(to, int64(len(log)))
+		if from < to {
+			messages = log[from:to]
+		}
+	}
+	t.mu.Unlock()
+
+	for _, msg := range messages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(msg) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (t *Transport) CommittedOffsets(ctx context.Context, groupID, topic string) (map[int]int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	offsets := make(map[int]int64)
+	if g, ok := t.topic(topic).groups[groupID]; ok {
+		maps.Copy(offsets, g.committed)
+	}
+	return offsets, nil
+}
+
+// CommitOffsets overwrites the group's offsets. Members of the group pick them
+// up on their next rebalance.
+func (t *Transport) CommitOffsets(ctx context.Context, groupID, topic string, offsets map[int]int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tp := t.topic(topic)
+	g, ok := tp.groups[groupID]
+	if !ok {
+		g = &group{committed: make(map[int]int64)}
+		tp.groups[groupID] = g
+	}
+	maps.Copy(g.committed, offsets)
+	return nil
+}
+
+// topic returns the named topic, creating it on first use. t.mu must be held.
+func (t *Transport) topic(name string) *topic {
+	tp, ok := t.topics[name]
+	if !ok {
+		tp = &topic{
+			logs:   make([][]transport.Message, t.partitions),
+			groups: make(map[string]*group),
+		}
+		t.topics[name] = tp
+	}
+	return tp
+}
+
+// notify wakes every blocked Fetch. t.mu must be held.
+func (t *Transport) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+type publisher struct {
+	transport    *Transport
+	partitioning transport.Partitioning
+	completion   func(msgs []transport.Message, err error)
+}
+
+// Publish appends msgs to their partitions before returning, so the
+// publisher is synchronous and durable whatever its configuration.
+func (p *publisher) Publish(ctx context.Context, msgs ...transport.Message) error {
+	written, err := p.append(ctx, msgs)
+	if p.completion != nil {
+		p.completion(written, err)
+	}
+	return err
+}
+
+func (p *publisher) append(ctx context.Context, msgs []transport.Message) ([]transport.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return msgs, err
+	}
+	for _, msg := range msgs {
+		if msg.Topic == "" {
+			return msgs, errNoTopic
+		}
+	}
+
+	t := p.transport
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	written := make([]transport.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		tp := t.topic(msg.Topic)
+		partition := p.partition(tp, msg.Key)
+		stored := transport.Message{
+			Topic:     msg.Topic,
+			Partition: partition,
+			Offset:    int64(len(tp.logs[partition])),
+			Key:       bytes.Clone(msg.Key),
+			Value:     bytes.Clone(msg.Value),
+			Headers:   slices.Clone(msg.Headers),
+			Time:      now,
+		}
+		tp.logs[partition] = append(tp.logs[partition], stored)
+		written = append(written, stored)
+	}
+	t.notify()
+	return written, nil
+}
+
+func (p *publisher) partition(tp *topic, key []byte) int {
+	if p.partitioning == transport.PartitionByKey && len(key) > 0 {
+		h := fnv.New32a()
+		h.Write(key)
+		return int(h.Sum32() % uint32(len(tp.logs)))
+	}
+	partition := tp.next
+	tp.next = (tp.next + 1) % len(tp.logs)
+	return partition
+}
+
+func (p *publisher) Close() error {
+	return nil
+}
+
+type subscription struct {
+	transport *Transport
+	config    transport.SubscriptionConfig
+	group     *group
+	// generation is the group generation assigned and positions were computed
+	// for.
+	generation int
+	assigned   []int
+	positions  map[int]int64
+	next       int
+	closed     bool
+}
+
+func (s *subscription) Fetch(ctx context.Context) (transport.Message, error) {
+	t := s.transport
+	for {
+		t.mu.Lock()
+		if s.closed {
+			t.mu.Unlock()
+			return transport.Message{}, transport.ErrClosed
+		}
+		tp := t.topic(s.config.Topic)
+		s.rebalance(tp)
+		if msg, ok := s.poll(tp); ok {
+			t.mu.Unlock()
+			return msg, nil
+		}
+		changed := t.changed
+		t.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return transport.Message{}, ctx.Err()
+		}
+	}
+}
+
+// rebalance takes up the member's share of partitions after the group
+// changed, resuming each from the group's committed offset. Partition p goes
+// to the member at index p modulo the group size. t.mu must be held.
+func (s *subscription) rebalance(tp *topic) {
+	g := s.group
+	if g == nil || s.generation == g.generation {
+		return
+	}
+	s.generation = g.generation
+	s.assigned = s.assigned[:0]
+	clear(s.positions)
+	s.next = 0
+
+	index := slices.Index(g.members, s)
+	for p := range tp.logs {
+		if p%len(g.members) != index {
+			continue
+		}
+		s.assigned = append(s.assigned, p)
+		if offset, ok := g.committed[p]; ok {
+			s.positions[p] = offset
+		} else if s.config.StartOffset == transport.StartLatest {
+			s.positions[p] = int64(len(tp.logs[p]))
+		} else {
+			s.positions[p] = 0
+		}
+	}
+}
+
+// poll returns the next unread message, taking partitions in turn so a busy
+// partition does not starve the others. t.mu must be held.
+func (s *subscription) poll(tp *topic) (transport.Message, bool) {
+	for i := range s.assigned {
+		k := (s.next + i) % len(s.assigned)
+		p := s.assigned[k]
+		offset := s.positions[p]
+		if offset < int64(len(tp.logs[p])) {
+			s.positions[p] = offset + 1
+			s.next = (k + 1) % len(s.assigned)
+			return tp.logs[p][offset], true
+		}
+	}
+	return transport.Message{}, false
+}
+
+// Commit records the offsets after msgs for the group. Commits for partitions
+// the member no longer owns are ignored; the new owner reads them again.
+func (s *subscription) Commit(ctx context.Context, msgs ...transport.Message) error {
+	if s.group == nil {
+		return nil
+	}
+	t := s.transport
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.rebalance(t.topic(s.config.Topic))
+	for _, msg := range msgs {
+		if msg.Topic != s.config.Topic || !slices.Contains(s.assigned, msg.Partition) {
+			continue
+		}
+		offset := msg.Offset + 1
+		if committed, ok := s.group.committed[msg.Partition]; !ok || offset > committed {
+			s.group.committed[msg.Partition] = offset
+		}
+		// A rebalance since the message was fetched rewound the position to
+		// the previous commit.
+		s.positions[msg.Partition] = max(s.positions[msg.Partition], offset)
+	}
+	return nil
+}
+
+func (s *subscription) Close() error {
+	t := s.transport
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if g := s.group; g != nil {
+		g.members = slices.DeleteFunc(g.members, func(m *subscription) bool { return m == s })
+		g.generation++
+	}
+	t.notify()
+	return nil
+}
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"feed-api/internal/transport"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func publish(t *testing.T, p transport.Publisher, topic string, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		msg := transport.Message{Topic: topic, Key: []byte(key), Value: []byte(key)}
+		if err := p.Publish(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func fetch(t *testing.T, s transport.Subscription) transport.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := s.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func assertEmpty(t *testing.T, s transport.Subscription) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if msg, err := s.Fetch(ctx); err == nil {
+		t.Fatalf("unexpected message %s", msg.Value)
+	}
+}
+
+func TestPartitionByKeyKeepsOrderPerKey(t *testing.T) {
+	broker := New(3)
+	publisher := broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey})
+	for i := range 5 {
+		publish(t, publisher, "events", "a", "b", fmt.Sprintf("c%d", i))
+	}
+
+	partitions := make(map[string]int)
+	for _, p := range []int{0, 1, 2} {
+		var last int64 = -1
+		err := broker.Read(context.Background(), "events", p, 0, 100, func(msg transport.Message) bool {
+			if msg.Offset <= last {
+				t.Fatalf("partition %d: offset %d after %d", p, msg.Offset, last)
+			}
+			last = msg.Offset
+			if prev, ok := partitions[string(msg.Key)]; ok && prev != p {
+				t.Fatalf("key %s in partitions %d and %d", msg.Key, prev, p)
+			}
+			partitions[string(msg.Key)] = p
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(partitions) != 7 {
+		t.Fatalf("got %d keys, want 7", len(partitions))
+	}
+}
+
+func TestGroupSharesPartitionsAndResumesFromCommit(t *testing.T) {
+	broker := New(2)
+	publisher := broker.NewPublisher(transport.PublisherConfig{})
+	config := transport.SubscriptionConfig{Topic: "events", GroupID: "workers"}
+
+	first := broker.Subscribe(config)
+	second := broker.Subscribe(config)
+	publish(t, publisher, "events", "m1", "m2", "m3", "m4")
+
+	// Round-robin publishing puts m1 and m3 on partition 0, m2 and m4 on
+	// partition 1, and each member owns one partition.
+	m1 := fetch(t, first)
+	m2 := fetch(t, second)
+	if m1.Partition == m2.Partition {
+		t.Fatalf("both members read partition %d", m1.Partition)
+	}
+	if err := first.Commit(context.Background(), m1); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Commit(context.Background(), m2); err != nil {
+		t.Fatal(err)
+	}
+	// The second member reads m4 but closes before committing it.
+	fetch(t, second)
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Fetch(context.Background()); !errors.Is(err, transport.ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+
+	// The remaining member takes over both partitions from their commits.
+	got := map[string]bool{}
+	for range 2 {
+		got[string(fetch(t, first).Value)] = true
+	}
+	if !got["m3"] || !got["m4"] {
+		t.Fatalf("got %v, want m3 and m4", got)
+	}
+	assertEmpty(t, first)
+}
+
+func TestStartLatestSkipsEarlierMessages(t *testing.T) {
+	broker := New(1)
+	publisher := broker.NewPublisher(transport.PublisherConfig{})
+	publish(t, publisher, "events", "old")
+
+	subscription := broker.Subscribe(transport.SubscriptionConfig{Topic: "events", StartOffset: transport.StartLatest})
+	publish(t, publisher, "events", "new")
+
+	if msg := fetch(t, subscription); string(msg.Value) != "new" {
+		t.Fatalf("got %s, want new", msg.Value)
+	}
+	assertEmpty(t, subscription)
+}
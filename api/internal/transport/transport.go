@@ -0,0 +1,133 @@
+// Package transport abstracts the message broker behind publishers,
+// consumer-group subscriptions and direct topic reads. Drivers live in the
+// kafka and memory subpackages.
+package transport
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrClosed is returned by a Subscription that has been closed.
+var ErrClosed = errors.New("transport: subscription closed")
+
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a record on a topic. Partition, Offset and Time are assigned by
+// the broker and ignored when publishing.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+	Time      time.Time
+}
+
+// Header returns the value of the first header named key.
+func (m Message) Header(key string) (string, bool) {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
+type Partitioning int
+
+const (
+	// PartitionSpread spreads messages across partitions to balance load.
+	PartitionSpread Partitioning = iota
+	// PartitionByKey sends messages with the same key to the same partition.
+	PartitionByKey
+)
+
+// Acks is how many replicas must have a message before a write succeeds.
+type Acks int
+
+const (
+	AckAll Acks = iota
+	AckLeader
+	AckNone
+)
+
+type PublisherConfig struct {
+	// Async makes Publish return before the broker has acknowledged the
+	// messages. Write errors are then only reported to Completion.
+	Async        bool
+	Acks         Acks
+	Partitioning Partitioning
+	// Completion, if set, is called with the result of every write.
+	Completion func(msgs []Message, err error)
+}
+
+type StartOffset int
+
+const (
+	// StartEarliest reads a partition from its first retained message.
+	StartEarliest StartOffset = iota
+	// StartLatest only reads messages published after the subscription
+	// joined.
+	StartLatest
+)
+
+// SubscriptionConfig describes a subscription to a topic. Subscriptions with
+// the same GroupID share the topic's partitions and committed offsets; the
+// StartOffset only applies to partitions the group has never committed. An
+// empty GroupID reads every partition and keeps no offsets.
+type SubscriptionConfig struct {
+	Topic       string
+	GroupID     string
+	StartOffset StartOffset
+}
+
+type Publisher interface {
+	// Publish writes msgs to the topics they name.
+	Publish(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Subscription delivers the messages of each assigned partition in order.
+// Committing a message commits every message before it on its partition;
+// uncommitted messages are delivered again to whichever member of the group
+// owns the partition next.
+type Subscription interface {
+	Fetch(ctx context.Context) (Message, error)
+	Commit(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Inspector reads topics directly, outside any consumer group.
+type Inspector interface {
+	Partitions(ctx context.Context, topic string) ([]int, error)
+	// Offsets returns the offset of the first retained message of the
+	// partition and the offset the next published message will get.
+	Offsets(ctx context.Context, topic string, partition int) (first, last int64, err error)
+	// Read calls fn for each message of the partition from offset from up to,
+	// but not including, offset to, until fn returns false.
+	Read(ctx context.Context, topic string, partition int, from, to int64, fn func(msg Message) bool) error
+}
+
+// GroupOffsets reads and sets a consumer group's committed offsets directly,
+// for groups that track their progress without subscribing.
+type GroupOffsets interface {
+	// CommittedOffsets returns the offset of the next message the group will
+	// consume, for each partition of the topic it has committed.
+	CommittedOffsets(ctx context.Context, groupID, topic string) (map[int]int64, error)
+	// CommitOffsets sets the offset of the next message the group will
+	// consume for the given partitions.
+	CommitOffsets(ctx context.Context, groupID, topic string, offsets map[int]int64) error
+}
+
+type Transport interface {
+	Inspector
+	GroupOffsets
+	NewPublisher(config PublisherConfig) Publisher
+	Subscribe(config SubscriptionConfig) Subscription
+}
@@ -5,74 +5,350 @@ import (
 	"encoding/json"
 	"errors"
 	"feed-api/internal/messaging"
+	"feed-api/internal/transport"
+	"fmt"
 	"log"
+	"sync"
 	"time"
-
-	"github.com/segmentio/kafka-go"
 )
 
 type Consumer interface {
 	Start(ctx context.Context)
 }
 
-type KafkaConsumer[T messaging.Eventable] struct {
-	reader    *kafka.Reader
-	processor Processor[T]
+// stage is one step of the retry chain: the topic it reads, how long events
+// wait there before being processed, and where they go when they fail.
+type stage struct {
+	topic      string
+	delay      time.Duration
+	next       string
+	nextDelay  time.Duration
+	deadLetter string
 }
 
-func NewConsumer[T messaging.Eventable](brokers []string, topic, groupID string, processor Processor[T]) *KafkaConsumer[T] {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: brokers,
-		Topic:   topic,
-		GroupID: groupID,
-	})
+type StageConsumer[T messaging.Eventable] struct {
+	subscription transport.Subscription
+	processor    Processor[T]
+	policy       RetryPolicy
+	pool         PoolConfig
+	forwarder    *Forwarder
+	stage        stage
+}
 
-	return &KafkaConsumer[T]{
-		reader:    reader,
-		processor: processor,
+func NewConsumer[T messaging.Eventable](
+	subscription transport.Subscription,
+	processor Processor[T],
+	policy RetryPolicy,
+	pool PoolConfig,
+	forwarder *Forwarder,
+	stage stage,
+) *StageConsumer[T] {
+	pool.Concurrency = max(pool.Concurrency, 1)
+	return &StageConsumer[T]{
+		subscription: subscription,
+		processor:    processor,
+		policy:       policy,
+		pool:         pool,
+		forwarder:    forwarder,
+		stage:        stage,
 	}
 }
 
-func (c *KafkaConsumer[T]) Start(ctx context.Context) {
-	log.Println("Starting KafkaConsumer...")
-	defer c.reader.Close()
+// Start fetches messages until ctx is done and hands them to a pool of lanes.
+// Messages that must stay in order share a lane, so events are processed in
+// the order they were published per partition, or per key. Committing an
+// offset commits everything before it, so a message is only committed once it
+// and every message fetched before it on its partition were processed or
+// handed to the next stage.
+//
+// Once ctx is done Start stops fetching, lets messages already being
+// processed finish within the drain timeout, commits them and returns.
+// Messages that were queued but not started are redelivered.
+func (c *StageConsumer[T]) Start(ctx context.Context) {
+	log.Printf("Starting consumer for %s with %d lane(s)...", c.stage.topic, c.pool.Concurrency)
+	defer c.subscription.Close()
+
+	// In-flight work outlives ctx so it can drain; abort cancels it once the
+	// drain timeout has passed.
+	work, abort := context.WithCancel(context.WithoutCancel(ctx))
+	defer abort()
+
+	tracker := newOffsetTracker()
+	completions := make(chan transport.Message, c.pool.Concurrency*laneBuffer)
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		c.commitLoop(context.WithoutCancel(ctx), tracker, completions)
+	}()
+
+	lanes := make([]chan transport.Message, c.pool.Concurrency)
+	var wg sync.WaitGroup
+	for i := range lanes {
+		lanes[i] = make(chan transport.Message, max(laneBuffer, c.pool.BatchSize))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.runLane(ctx, work, lanes[i], completions)
+		}()
+	}
+
+	c.fetchLoop(ctx, tracker, lanes)
+
+	for _, lane := range lanes {
+		close(lane)
+	}
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(c.pool.DrainTimeout):
+		log.Printf("Consumer for %s did not drain in %s, cancelling in-flight messages", c.stage.topic, c.pool.DrainTimeout)
+		abort()
+		<-drained
+	}
+	close(completions)
+	<-committed
+	log.Printf("Consumer for %s stopped", c.stage.topic)
+}
 
+func (c *StageConsumer[T]) fetchLoop(ctx context.Context, tracker *offsetTracker, lanes []chan transport.Message) {
 	for {
-		msg, err := c.reader.FetchMessage(ctx)
+		msg, err := c.subscription.Fetch(ctx)
 		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				log.Println("KafkaConsumer context canceled, stopping...")
+			if errors.Is(err, context.Canceled) || errors.Is(err, transport.ErrClosed) {
+				log.Println("Consumer stopping...")
 				return
 			}
 
-			var kafkaErr kafka.Error
-			if errors.As(err, &kafkaErr) && errors.Is(kafkaErr, kafka.NotCoordinatorForGroup) {
-				log.Println("KafkaConsumer not coordinator for group, retrying...")
-				time.Sleep(3 * time.Second)
-				continue
+			log.Printf("Error fetching message: %v", err)
+			continue
+		}
+
+		tracker.fetched(msg)
+		select {
+		case lanes[c.pool.lane(msg)] <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// commitLoop commits the contiguous prefix of completed messages, batching
+// whatever completed since the previous commit.
+func (c *StageConsumer[T]) commitLoop(ctx context.Context, tracker *offsetTracker, completions <-chan transport.Message) {
+	for msg := range completions {
+		latest := make(map[int]transport.Message)
+		record := func(msg transport.Message) {
+			if commit, ok := tracker.completed(msg); ok {
+				latest[commit.Partition] = commit
+			}
+		}
+		record(msg)
+	batch:
+		for {
+			select {
+			case next, ok := <-completions:
+				if !ok {
+					break batch
+				}
+				record(next)
+			default:
+				break batch
 			}
+		}
+		if len(latest) == 0 {
+			continue
+		}
 
-			log.Printf("Error fetching message: %v", err)
+		commits := make([]transport.Message, 0, len(latest))
+		for _, commit := range latest {
+			commits = append(commits, commit)
+		}
+		if err := c.subscription.Commit(ctx, commits...); err != nil {
+			log.Printf("Error committing messages: %v", err)
+		}
+	}
+}
+
+// runLane processes the messages of a lane in order until it is closed,
+// reporting each handled message to completions. Messages taken after stop is
+// done are skipped. Events read from the main topic are batched when the
+// processor supports it.
+func (c *StageConsumer[T]) runLane(stop, work context.Context, lane <-chan transport.Message, completions chan<- transport.Message) {
+	batcher, batching := c.processor.(BatchProcessor[T])
+	batching = batching && c.pool.BatchSize > 1 && c.stage.delay == 0
+
+	for msg := range lane {
+		if stop.Err() != nil {
 			continue
 		}
 
+		if batching {
+			batch := c.fillBatch(msg, lane)
+			if err := c.handleBatch(stop, work, batcher, batch, completions); err != nil {
+				log.Printf("Consumer stopped before handling batch: %v", err)
+			}
+			continue
+		}
+
+		if err := c.handle(stop, work, msg); err != nil {
+			log.Printf("Consumer stopped before handling message: %v", err)
+			continue
+		}
+		completions <- msg
+	}
+}
+
+// fillBatch collects messages from lane after first until the batch is full,
+// the batch timeout passes or the lane is closed.
+func (c *StageConsumer[T]) fillBatch(first transport.Message, lane <-chan transport.Message) []transport.Message {
+	batch := []transport.Message{first}
+	timer := time.NewTimer(c.pool.BatchTimeout)
+	defer timer.Stop()
+
+	for len(batch) < c.pool.BatchSize {
+		select {
+		case msg, ok := <-lane:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, msg)
+		case <-timer.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+// handleBatch processes msgs with a single ProcessBatch call. Events that
+// cannot be decoded go to the dead-letter topic; if the batch fails, its
+// events are handled one by one with the usual retries. Like handle, it
+// returns an error only when a context is done.
+func (c *StageConsumer[T]) handleBatch(stop, ctx context.Context, batcher BatchProcessor[T], msgs []transport.Message, completions chan<- transport.Message) error {
+	if err := stop.Err(); err != nil {
+		return err
+	}
+
+	events := make([]*messaging.Event[T], 0, len(msgs))
+	decoded := make([]transport.Message, 0, len(msgs))
+	for _, msg := range msgs {
 		var event messaging.Event[T]
-		err = json.Unmarshal(msg.Value, &event)
-		if err != nil {
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
 			log.Println("Error unmarshalling message", err)
-			if commitErr := c.reader.CommitMessages(ctx, msg); commitErr != nil {
-				log.Printf("Error committing poison message: %v", commitErr)
+			if err = c.forward(ctx, c.stage.deadLetter, 0, msg, fmt.Errorf("decode event: %w", err), 1); err != nil {
+				return err
 			}
+			completions <- msg
 			continue
 		}
+		events = append(events, &event)
+		decoded = append(decoded, msg)
+	}
+	if len(events) == 0 {
+		return nil
+	}
 
-		if err = c.processor.Process(ctx, &event); err != nil {
-			log.Printf("Error processing message: %v", err)
-			continue
+	err := batcher.ProcessBatch(ctx, events)
+	if err == nil {
+		for _, msg := range decoded {
+			completions <- msg
 		}
+		return nil
+	}
+	log.Printf("Error processing batch of %d messages, retrying one by one: %v", len(events), err)
+
+	for _, msg := range decoded {
+		if err = c.handle(stop, ctx, msg); err != nil {
+			return err
+		}
+		completions <- msg
+	}
+	return nil
+}
 
-		if err = c.reader.CommitMessages(ctx, msg); err != nil {
-			log.Printf("Error committing message: %v", err)
+// handle processes msg with ctx, waiting for its retry time unless stop is
+// done first. It returns an error only when either context is done.
+func (c *StageConsumer[T]) handle(stop, ctx context.Context, msg transport.Message) error {
+	if err := c.waitForRetry(stop, msg); err != nil {
+		return err
+	}
+
+	var event messaging.Event[T]
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		log.Println("Error unmarshalling message", err)
+		return c.forward(ctx, c.stage.deadLetter, 0, msg, fmt.Errorf("decode event: %w", err), 1)
+	}
+
+	var err error
+	for attempt := 1; attempt <= c.policy.MaxAttempts; attempt++ {
+		if err = c.processor.Process(ctx, &event); err == nil {
+			return nil
+		}
+		log.Printf("Error processing message (attempt %d/%d): %v", attempt, c.policy.MaxAttempts, err)
+		if attempt < c.policy.MaxAttempts {
+			if sleepErr := sleep(ctx, c.policy.backoff(attempt)); sleepErr != nil {
+				return sleepErr
+			}
+		}
+	}
+
+	if fwdErr := c.forward(ctx, c.stage.next, c.stage.nextDelay, msg, err, c.policy.MaxAttempts); fwdErr != nil {
+		return fwdErr
+	}
+	if c.stage.next == c.stage.deadLetter {
+		c.deadLettered(ctx, &event, err)
+	}
+	return nil
+}
+
+// deadLettered tells the processor that event was dead-lettered. The event is
+// already in the dead-letter topic, so failures are only logged.
+func (c *StageConsumer[T]) deadLettered(ctx context.Context, event *messaging.Event[T], cause error) {
+	dl, ok := c.processor.(DeadLetterProcessor[T])
+	if !ok {
+		return
+	}
+	if err := dl.DeadLettered(ctx, event, cause); err != nil {
+		log.Println("Error handling dead-lettered message:", err)
+	}
+}
+
+// waitForRetry delays an event read from a retry topic until its retry time.
+func (c *StageConsumer[T]) waitForRetry(ctx context.Context, msg transport.Message) error {
+	if c.stage.delay == 0 {
+		return nil
+	}
+	raw, ok := msg.Header(messaging.HeaderRetryAt)
+	if !ok {
+		return nil
+	}
+	retryAt, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return nil
+	}
+	return sleep(ctx, time.Until(retryAt))
+}
+
+// forward keeps trying to hand msg to topic until it succeeds or ctx is done,
+// so a failed event is never skipped.
+func (c *StageConsumer[T]) forward(ctx context.Context, topic string, delay time.Duration, msg transport.Message, cause error, attempts int) error {
+	var retryAt time.Time
+	if delay > 0 {
+		retryAt = time.Now().Add(delay)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.forwarder.Forward(ctx, topic, msg, cause, attempts, retryAt)
+		if err == nil {
+			log.Printf("Forwarded failed message to %s", topic)
+			return nil
+		}
+		log.Printf("Error forwarding message to %s: %v", topic, err)
+		if sleepErr := sleep(ctx, c.policy.backoff(attempt)); sleepErr != nil {
+			return sleepErr
 		}
 	}
 }
@@ -0,0 +1,299 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feed-api/internal/messaging"
+	"feed-api/internal/transport"
+	"feed-api/internal/transport/memory"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errProcessing = errors.New("database unavailable")
+
+func fastRetryPolicy(attempts int, delays ...time.Duration) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		RetryDelays:    delays,
+	}
+}
+
+func newStageConsumer(broker *memory.Transport, processor Processor[*testMessage], policy RetryPolicy, s stage) *StageConsumer[*testMessage] {
+	forwarder := NewForwarder(broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey}))
+	subscription := broker.Subscribe(transport.SubscriptionConfig{Topic: s.topic, GroupID: "workers"})
+	return NewConsumer[*testMessage](subscription, processor, policy, DefaultPoolConfig(), forwarder, s)
+}
+
+func testEvent(t *testing.T, topic string, msg *testMessage) transport.Message {
+	t.Helper()
+	value, err := json.Marshal(messaging.NewEventMessage(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transport.Message{Topic: topic, Key: []byte(msg.Author), Value: value}
+}
+
+func TestHandleRetriesInProcessBeforeForwarding(t *testing.T) {
+	broker := memory.New(1)
+	var calls atomic.Int32
+	processor := processorFunc(func(ctx context.Context, event *messaging.Event[*testMessage]) error {
+		if calls.Add(1) == 1 {
+			return errProcessing
+		}
+		return nil
+	})
+	c := newStageConsumer(broker, processor, fastRetryPolicy(3), stage{topic: "events", next: "events-dlq", deadLetter: "events-dlq"})
+
+	ctx := context.Background()
+	if err := c.handle(ctx, ctx, testEvent(t, "events", &testMessage{Author: "a"})); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("processed %d times, want 2", calls.Load())
+	}
+	if forwarded := readTopic(t, broker, "events-dlq"); len(forwarded) != 0 {
+		t.Fatalf("forwarded %d events after a successful retry", len(forwarded))
+	}
+}
+
+func TestHandleForwardsToNextStageAfterMaxAttempts(t *testing.T) {
+	broker := memory.New(1)
+	var calls atomic.Int32
+	processor := processorFunc(func(ctx context.Context, event *messaging.Event[*testMessage]) error {
+		calls.Add(1)
+		return errProcessing
+	})
+	s := stage{topic: "events", next: "events-retry-1", nextDelay: time.Minute, deadLetter: "events-dlq"}
+	c := newStageConsumer(broker, processor, fastRetryPolicy(2), s)
+
+	ctx := context.Background()
+	msg := testEvent(t, "events", &testMessage{Author: "a"})
+	msg.Offset = 4
+	before := time.Now()
+	if err := c.handle(ctx, ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls.Load() != 2 {
+		t.Fatalf("processed %d times, want 2", calls.Load())
+	}
+	retried := readTopic(t, broker, "events-retry-1")
+	if len(retried) != 1 {
+		t.Fatalf("got %d events in the retry topic, want 1", len(retried))
+	}
+	if got := header(t, retried[0], messaging.HeaderAttempts); got != "2" {
+		t.Fatalf("got %s attempts, want 2", got)
+	}
+	if got := header(t, retried[0], messaging.HeaderError); got != errProcessing.Error() {
+		t.Fatalf("got error %q, want %q", got, errProcessing)
+	}
+	if got := header(t, retried[0], messaging.HeaderSourceOffset); got != "4" {
+		t.Fatalf("got source offset %s, want 4", got)
+	}
+	retryAt, err := time.Parse(time.RFC3339Nano, header(t, retried[0], messaging.HeaderRetryAt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retryAt.Before(before.Add(time.Minute)) {
+		t.Fatalf("retry at %v, want at least a minute after %v", retryAt, before)
+	}
+}
+
+func TestHandleDeadLettersUndecodableEvents(t *testing.T) {
+	broker := memory.New(1)
+	processor := processorFunc(func(ctx context.Context, event *messaging.Event[*testMessage]) error {
+		t.Error("undecodable event was processed")
+		return nil
+	})
+	s := stage{topic: "events", next: "events-retry-1", nextDelay: 10 * time.Second, deadLetter: "events-dlq"}
+	c := newStageConsumer(broker, processor, fastRetryPolicy(3), s)
+
+	ctx := context.Background()
+	if err := c.handle(ctx, ctx, transport.Message{Topic: "events", Value: []byte("not json")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if retried := readTopic(t, broker, "events-retry-1"); len(retried) != 0 {
+		t.Fatalf("undecodable event was sent to the retry topic")
+	}
+	dead := readTopic(t, broker, "events-dlq")
+	if len(dead) != 1 {
+		t.Fatalf("got %d dead letters, want 1", len(dead))
+	}
+	if got := header(t, dead[0], messaging.HeaderAttempts); got != "1" {
+		t.Fatalf("got %s attempts, want 1", got)
+	}
+	if got := header(t, dead[0], messaging.HeaderError); !strings.HasPrefix(got, "decode event") {
+		t.Fatalf("got error %q, want a decode error", got)
+	}
+}
+
+func TestHandleWaitsForRetryTime(t *testing.T) {
+	broker := memory.New(1)
+	var processedAt time.Time
+	processor := processorFunc(func(ctx context.Context, event *messaging.Event[*testMessage]) error {
+		processedAt = time.Now()
+		return nil
+	})
+	s := stage{topic: "events-retry-1", delay: 10 * time.Second, next: "events-dlq", deadLetter: "events-dlq"}
+	c := newStageConsumer(broker, processor, fastRetryPolicy(1), s)
+
+	retryAt := time.Now().Add(50 * time.Millisecond)
+	msg := testEvent(t, s.topic, &testMessage{Author: "a"})
+	msg.Headers = []transport.Header{{Key: messaging.HeaderRetryAt, Value: []byte(retryAt.UTC().Format(time.RFC3339Nano))}}
+
+	ctx := context.Background()
+	if err := c.handle(ctx, ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if processedAt.Before(retryAt) {
+		t.Fatalf("processed at %v, before its retry time %v", processedAt, retryAt)
+	}
+
+	// Stopping interrupts the wait and leaves the event unhandled.
+	msg.Headers = []transport.Header{{Key: messaging.HeaderRetryAt, Value: []byte(time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano))}}
+	stop, cancel := context.WithCancel(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := c.handle(stop, ctx, msg); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+// flakyPublisher fails the first fails publishes.
+type flakyPublisher struct {
+	mu        sync.Mutex
+	fails     int
+	published []transport.Message
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, msgs ...transport.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fails > 0 {
+		p.fails--
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, msgs...)
+	return nil
+}
+
+func (p *flakyPublisher) Close() error {
+	return nil
+}
+
+func TestForwardRetriesUntilPublished(t *testing.T) {
+	publisher := &flakyPublisher{fails: 3}
+	c := NewConsumer[*testMessage](nil, nil, fastRetryPolicy(1), DefaultPoolConfig(), NewForwarder(publisher), stage{})
+
+	ctx := context.Background()
+	if err := c.forward(ctx, "events-dlq", 0, transport.Message{Topic: "events"}, errProcessing, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(publisher.published) != 1 || publisher.fails != 0 {
+		t.Fatalf("published %d messages with %d failures left, want 1 after every failure", len(publisher.published), publisher.fails)
+	}
+
+	publisher.fails = 1 << 30
+	stop, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := c.forward(stop, "events-dlq", 0, transport.Message{Topic: "events"}, errProcessing, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the context error once it is done", err)
+	}
+}
+
+func TestWorkerMovesFailingEventsThroughRetryTopicsToDeadLetter(t *testing.T) {
+	broker := memory.New(1)
+	var calls atomic.Int32
+	processor := processorFunc(func(ctx context.Context, event *messaging.Event[*testMessage]) error {
+		calls.Add(1)
+		return errProcessing
+	})
+	policy := fastRetryPolicy(1, 10*time.Millisecond, 20*time.Millisecond)
+	pool := PoolConfig{Concurrency: 1, Ordering: OrderByPartition, DrainTimeout: time.Second, BatchSize: 1}
+	w := NewWorker[*testMessage](broker, "events", "workers", processor, policy, pool)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.Start(ctx)
+	publishTestMessages(t, broker, "events", &testMessage{Author: "a"})
+
+	deadline := time.After(2 * time.Second)
+	for len(readTopic(t, broker, "events-dlq")) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for the dead letter")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	cancel()
+	w.Stop()
+
+	for _, topic := range []string{RetryTopic("events", 1), RetryTopic("events", 2)} {
+		if n := len(readTopic(t, broker, topic)); n != 1 {
+			t.Fatalf("got %d events in %s, want 1", n, topic)
+		}
+	}
+	dead := readTopic(t, broker, "events-dlq")
+	if got := header(t, dead[0], messaging.HeaderAttempts); got != "3" {
+		t.Fatalf("got %s attempts, want one per stage", got)
+	}
+	if got := header(t, dead[0], messaging.HeaderSourceTopic); got != "events" {
+		t.Fatalf("got source topic %s, want events", got)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("processed %d times, want 3", calls.Load())
+	}
+
+	offsets, err := broker.CommittedOffsets(context.Background(), "workers", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offsets[0] != 1 {
+		t.Fatalf("committed offset %d on the source topic, want 1", offsets[0])
+	}
+}
+
+// deadLetterRecorder fails every event and records the ones dead-lettered.
+type deadLetterRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (p *deadLetterRecorder) Process(ctx context.Context, event *messaging.Event[*testMessage]) error {
+	return errProcessing
+}
+
+func (p *deadLetterRecorder) DeadLettered(ctx context.Context, event *messaging.Event[*testMessage], cause error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event.Data().Author+": "+cause.Error())
+	return nil
+}
+
+func TestHandleTellsProcessorOnlyAboutDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	broker := memory.New(1)
+	processor := &deadLetterRecorder{}
+
+	retrying := newStageConsumer(broker, processor, fastRetryPolicy(1), stage{topic: "events", next: "events-retry-1", deadLetter: "events-dlq"})
+	if err := retrying.handle(ctx, ctx, testEvent(t, "events", &testMessage{Author: "a"})); err != nil {
+		t.Fatal(err)
+	}
+	if len(processor.events) != 0 {
+		t.Fatalf("told the processor about %v with a retry stage left", processor.events)
+	}
+
+	last := newStageConsumer(broker, processor, fastRetryPolicy(1), stage{topic: "events-retry-1", next: "events-dlq", deadLetter: "events-dlq"})
+	if err := last.handle(ctx, ctx, testEvent(t, "events-retry-1", &testMessage{Author: "b"})); err != nil {
+		t.Fatal(err)
+	}
+	if want := "b: " + errProcessing.Error(); len(processor.events) != 1 || processor.events[0] != want {
+		t.Fatalf("told the processor about %v, want %q", processor.events, want)
+	}
+}
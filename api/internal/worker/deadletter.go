@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"context"
+	"feed-api/internal/messaging"
+	"feed-api/internal/transport"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Forwarder republishes failed events to retry and dead-letter topics with
+// their original key and payload.
+type Forwarder struct {
+	publisher transport.Publisher
+}
+
+// NewForwarder expects a synchronous publisher that partitions by key.
+func NewForwarder(publisher transport.Publisher) *Forwarder {
+	return &Forwarder{
+		publisher: publisher,
+	}
+}
+
+// Forward writes msg to topic. The source topic, partition and offset of the
+// first failure are kept across stages; error, attempt count and retry time
+// describe the latest one.
+func (f *Forwarder) Forward(ctx context.Context, topic string, msg transport.Message, cause error, attempts int, retryAt time.Time) error {
+	headers := make([]transport.Header, 0, len(msg.Headers)+7)
+	previousAttempts := 0
+	hasSource := false
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case messaging.HeaderAttempts:
+			previousAttempts, _ = strconv.Atoi(string(h.Value))
+		case messaging.HeaderError, messaging.HeaderRetryAt, messaging.HeaderFailedAt:
+		case messaging.HeaderSourceTopic:
+			hasSource = true
+			headers = append(headers, h)
+		default:
+			headers = append(headers, h)
+		}
+	}
+
+	if !hasSource {
+		headers = append(headers,
+			transport.Header{Key: messaging.HeaderSourceTopic, Value: []byte(msg.Topic)},
+			transport.Header{Key: messaging.HeaderSourcePartition, Value: []byte(strconv.Itoa(msg.Partition))},
+			transport.Header{Key: messaging.HeaderSourceOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		)
+	}
+	headers = append(headers,
+		transport.Header{Key: messaging.HeaderError, Value: []byte(cause.Error())},
+		transport.Header{Key: messaging.HeaderAttempts, Value: []byte(strconv.Itoa(previousAttempts + attempts))},
+		transport.Header{Key: messaging.HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+	if !retryAt.IsZero() {
+		headers = append(headers, transport.Header{Key: messaging.HeaderRetryAt, Value: []byte(retryAt.UTC().Format(time.RFC3339Nano))})
+	}
+
+	return f.publisher.Publish(ctx, transport.Message{
+		Topic:   topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+}
+
+func (f *Forwarder) Close() error {
+	log.Println("Closing forwarder")
+	return f.publisher.Close()
+}
@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"feed-api/internal/messaging"
+	"feed-api/internal/transport"
+	"feed-api/internal/transport/memory"
+	"testing"
+	"time"
+)
+
+// readTopic returns every message of topic, partition by partition.
+func readTopic(t *testing.T, broker *memory.Transport, topic string) []transport.Message {
+	t.Helper()
+	ctx := context.Background()
+	partitions, err := broker.Partitions(ctx, topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []transport.Message
+	for _, p := range partitions {
+		first, last, err := broker.Offsets(ctx, topic, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = broker.Read(ctx, topic, p, first, last, func(msg transport.Message) bool {
+			msgs = append(msgs, msg)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return msgs
+}
+
+func header(t *testing.T, msg transport.Message, key string) string {
+	t.Helper()
+	value, ok := msg.Header(key)
+	if !ok {
+		t.Fatalf("message has no %s header", key)
+	}
+	return value
+}
+
+func TestForwarderKeepsFirstSourceAndAddsUpAttempts(t *testing.T) {
+	broker := memory.New(1)
+	forwarder := NewForwarder(broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey}))
+	ctx := context.Background()
+
+	original := transport.Message{
+		Topic:     "events",
+		Partition: 2,
+		Offset:    7,
+		Key:       []byte("user-1"),
+		Value:     []byte(`{"id":"event-1"}`),
+		Headers:   []transport.Header{{Key: "trace-id", Value: []byte("abc")}},
+	}
+	retryAt := time.Now().Add(time.Minute)
+	if err := forwarder.Forward(ctx, "events-retry-2", original, errors.New("first failure"), 3, retryAt); err != nil {
+		t.Fatal(err)
+	}
+	retried := readTopic(t, broker, "events-retry-2")
+	if len(retried) != 1 {
+		t.Fatalf("got %d retried messages, want 1", len(retried))
+	}
+	if got := header(t, retried[0], messaging.HeaderRetryAt); got != retryAt.UTC().Format(time.RFC3339Nano) {
+		t.Fatalf("got retry time %s, want %s", got, retryAt.UTC().Format(time.RFC3339Nano))
+	}
+
+	if err := forwarder.Forward(ctx, "events-dlq", retried[0], errors.New("second failure"), 2, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	dead := readTopic(t, broker, "events-dlq")
+	if len(dead) != 1 {
+		t.Fatalf("got %d dead letters, want 1", len(dead))
+	}
+	msg := dead[0]
+	if string(msg.Key) != "user-1" || string(msg.Value) != `{"id":"event-1"}` {
+		t.Fatalf("got key %q and value %q, want the original", msg.Key, msg.Value)
+	}
+	for key, want := range map[string]string{
+		"trace-id":                      "abc",
+		messaging.HeaderSourceTopic:     "events",
+		messaging.HeaderSourcePartition: "2",
+		messaging.HeaderSourceOffset:    "7",
+		messaging.HeaderError:           "second failure",
+		messaging.HeaderAttempts:        "5",
+	} {
+		if got := header(t, msg, key); got != want {
+			t.Errorf("header %s: got %q, want %q", key, got, want)
+		}
+	}
+	if _, ok := msg.Header(messaging.HeaderRetryAt); ok {
+		t.Error("dead letter kept the retry time of the previous stage")
+	}
+	for _, key := range []string{messaging.HeaderError, messaging.HeaderSourceTopic, messaging.HeaderFailedAt} {
+		n := 0
+		for _, h := range msg.Headers {
+			if h.Key == key {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Errorf("got %d %s headers, want 1", n, key)
+		}
+	}
+}
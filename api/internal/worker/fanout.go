@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"log"
+)
+
+const DefaultFanOutBatchSize = 500
+
+type FanOut[T Eventable] interface {
+	FanOut(ctx context.Context, msg T) error
+}
+
+// TimelineFanOut writes a message into the home timeline of each of its
+// author's followers and then marks it as fanned out. Authors with more than
+// threshold followers are skipped; their messages stay unmarked and are
+// merged into home timelines at read time.
+type TimelineFanOut[T Authored] struct {
+	repo      TimelineRepository[T]
+	batchSize int
+	threshold int
+}
+
+func NewTimelineFanOut[T Authored](repo TimelineRepository[T], batchSize, threshold int) *TimelineFanOut[T] {
+	if batchSize <= 0 {
+		batchSize = DefaultFanOutBatchSize
+	}
+	return &TimelineFanOut[T]{
+		repo:      repo,
+		batchSize: batchSize,
+		threshold: threshold,
+	}
+}
+
+func (f *TimelineFanOut[T]) FanOut(ctx context.Context, msg T) error {
+	authorID := msg.UserID()
+
+	followers, err := f.repo.CountFollowers(ctx, authorID)
+	if err != nil {
+		return err
+	}
+	if followers > f.threshold {
+		log.Printf("Skipping fan-out for %s with %d followers", authorID, followers)
+		return nil
+	}
+
+	after := ""
+	for {
+		followerIDs, err := f.repo.GetFollowerIDs(ctx, authorID, after, f.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(followerIDs) == 0 {
+			return f.repo.MarkFannedOut(ctx, msg)
+		}
+
+		if err = f.repo.AddTimelineEntries(ctx, followerIDs, msg); err != nil {
+			return err
+		}
+
+		if len(followerIDs) < f.batchSize {
+			return f.repo.MarkFannedOut(ctx, msg)
+		}
+		after = followerIDs[len(followerIDs)-1]
+	}
+}
@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func (m *testMessage) UserID() string {
+	return m.Author
+}
+
+// fakeTimelines serves the followers of every author from followers, sorted
+// by id, and records the timeline writes.
+type fakeTimelines struct {
+	followers  []string
+	count      int
+	batches    [][]string
+	marked     []*testMessage
+	addEntries error
+}
+
+func (r *fakeTimelines) CountFollowers(ctx context.Context, userID string) (int, error) {
+	return r.count, nil
+}
+
+func (r *fakeTimelines) GetFollowerIDs(ctx context.Context, userID, after string, limit int) ([]string, error) {
+	var ids []string
+	for _, id := range r.followers {
+		if id > after && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeTimelines) AddTimelineEntries(ctx context.Context, followerIDs []string, msg *testMessage) error {
+	if r.addEntries != nil {
+		return r.addEntries
+	}
+	r.batches = append(r.batches, followerIDs)
+	return nil
+}
+
+func (r *fakeTimelines) MarkFannedOut(ctx context.Context, msg *testMessage) error {
+	r.marked = append(r.marked, msg)
+	return nil
+}
+
+func TestTimelineFanOut(t *testing.T) {
+	msg := &testMessage{Author: "author"}
+	tests := []struct {
+		name        string
+		followers   []string
+		count       int
+		addEntries  error
+		wantBatches int
+		wantMarked  bool
+		wantErr     bool
+	}{
+		{"no followers", nil, 0, nil, 0, true, false},
+		{"partial last batch", []string{"a", "b", "c"}, 3, nil, 2, true, false},
+		{"full last batch", []string{"a", "b", "c", "d"}, 4, nil, 2, true, false},
+		{"above threshold", []string{"a", "b", "c", "d", "e", "f"}, 6, nil, 0, false, false},
+		{"write failure", []string{"a"}, 1, errors.New("timeout"), 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeTimelines{followers: tt.followers, count: tt.count, addEntries: tt.addEntries}
+			err := NewTimelineFanOut[*testMessage](repo, 2, 5).FanOut(context.Background(), msg)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v", err)
+			}
+			if len(repo.batches) != tt.wantBatches {
+				t.Fatalf("got %d batches %v, want %d", len(repo.batches), repo.batches, tt.wantBatches)
+			}
+			if written := slices.Concat(repo.batches...); tt.wantBatches > 0 && !slices.Equal(written, tt.followers) {
+				t.Fatalf("wrote timelines of %v, want %v", written, tt.followers)
+			}
+			if marked := len(repo.marked) == 1; marked != tt.wantMarked {
+				t.Fatalf("got marked %v, want %v", marked, tt.wantMarked)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"feed-api/internal/transport"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultConcurrency  = 4
+	DefaultDrainTimeout = 30 * time.Second
+	DefaultBatchSize    = 100
+	DefaultBatchTimeout = 10 * time.Millisecond
+
+	laneBuffer = 16
+)
+
+// Ordering decides which messages a consumer may process concurrently.
+type Ordering string
+
+const (
+	// OrderByPartition processes each partition one message at a time, so
+	// different partitions run in parallel.
+	OrderByPartition Ordering = "partition"
+	// OrderByKey processes each key one message at a time, so different keys
+	// of the same partition also run in parallel.
+	OrderByKey Ordering = "key"
+)
+
+type PoolConfig struct {
+	// Concurrency is the number of lanes of each consumer. A lane processes one
+	// message, or one batch, at a time.
+	Concurrency int
+	Ordering    Ordering
+	// DrainTimeout bounds how long Stop waits for in-flight messages. Work
+	// still running then is cancelled and redelivered later.
+	DrainTimeout time.Duration
+	// BatchSize is the most messages a lane hands to a BatchProcessor at once,
+	// and BatchTimeout how long it waits for a batch to fill. A BatchSize of 1
+	// disables batching.
+	BatchSize    int
+	BatchTimeout time.Duration
+}
+
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		Concurrency:  DefaultConcurrency,
+		Ordering:     OrderByPartition,
+		DrainTimeout: DefaultDrainTimeout,
+		BatchSize:    DefaultBatchSize,
+		BatchTimeout: DefaultBatchTimeout,
+	}
+}
+
+func ParseOrdering(raw string) (Ordering, error) {
+	switch ordering := Ordering(raw); ordering {
+	case OrderByPartition, OrderByKey:
+		return ordering, nil
+	default:
+		return "", fmt.Errorf("unknown ordering %q", raw)
+	}
+}
+
+// lane returns the lane msg must be processed on. Messages that have to stay
+// in order always share a lane.
+func (c PoolConfig) lane(msg transport.Message) int {
+	if c.Concurrency <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s/%d", msg.Topic, msg.Partition)
+	if c.Ordering == OrderByKey {
+		h.Write(msg.Key)
+	}
+	return int(h.Sum32() % uint32(c.Concurrency))
+}
+
+// offsetTracker finds, for each partition, the newest message that can be
+// committed: one whose predecessors since the last commit have all completed.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	// pending holds the fetched messages that are not yet committable, in
+	// offset order.
+	pending []transport.Message
+	done    map[int64]bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[int]*partitionOffsets),
+	}
+}
+
+func (t *offsetTracker) fetched(msg transport.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[msg.Partition]
+	// Offsets going backwards mean the partition was reassigned and is read
+	// again from its last commit.
+	if !ok || (len(p.pending) > 0 && msg.Offset <= p.pending[len(p.pending)-1].Offset) {
+		p = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[msg.Partition] = p
+	}
+	p.pending = append(p.pending, msg)
+}
+
+// completed marks msg as processed and returns the message to commit if the
+// contiguous completed prefix of its partition grew.
+func (t *offsetTracker) completed(msg transport.Message) (transport.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[msg.Partition]
+	if !ok {
+		return transport.Message{}, false
+	}
+	if !slices.ContainsFunc(p.pending, func(m transport.Message) bool { return m.Offset == msg.Offset }) {
+		return transport.Message{}, false
+	}
+	p.done[msg.Offset] = true
+
+	n := 0
+	for n < len(p.pending) && p.done[p.pending[n].Offset] {
+		delete(p.done, p.pending[n].Offset)
+		n++
+	}
+	if n == 0 {
+		return transport.Message{}, false
+	}
+	commit := p.pending[n-1]
+	p.pending = p.pending[n:]
+	return commit, true
+}
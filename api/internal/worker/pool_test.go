@@ -0,0 +1,265 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"feed-api/internal/messaging"
+	"feed-api/internal/transport"
+	"feed-api/internal/transport/memory"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMessage struct {
+	Author string `json:"author"`
+	Seq    int    `json:"seq"`
+}
+
+func (m *testMessage) MarshalJSON() ([]byte, error) {
+	type plain testMessage
+	return json.Marshal((*plain)(m))
+}
+
+type processorFunc func(ctx context.Context, event *messaging.Event[*testMessage]) error
+
+func (f processorFunc) Process(ctx context.Context, event *messaging.Event[*testMessage]) error {
+	return f(ctx, event)
+}
+
+func publishTestMessages(t *testing.T, broker transport.Transport, topic string, msgs ...*testMessage) {
+	t.Helper()
+	publisher := broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey})
+	for _, msg := range msgs {
+		value, err := json.Marshal(messaging.NewEventMessage(msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = publisher.Publish(context.Background(), transport.Message{Topic: topic, Key: []byte(msg.Author), Value: value})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestConsumer(broker transport.Transport, processor Processor[*testMessage], pool PoolConfig) *StageConsumer[*testMessage] {
+	subscription := broker.Subscribe(transport.SubscriptionConfig{Topic: "events", GroupID: "workers"})
+	forwarder := NewForwarder(broker.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey}))
+	s := stage{topic: "events", next: "events-dlq", deadLetter: "events-dlq"}
+	return NewConsumer[*testMessage](subscription, processor, DefaultRetryPolicy(), pool, forwarder, s)
+}
+
+func TestOffsetTrackerCommitsContiguousPrefix(t *testing.T) {
+	tracker := newOffsetTracker()
+	msgs := make([]transport.Message, 4)
+	for i := range msgs {
+		msgs[i] = transport.Message{Partition: 0, Offset: int64(i)}
+		tracker.fetched(msgs[i])
+	}
+
+	steps := []struct {
+		complete int
+		commit   int64
+	}{
+		{complete: 2, commit: -1},
+		{complete: 0, commit: 0},
+		{complete: 1, commit: 2},
+		{complete: 3, commit: 3},
+	}
+	for _, step := range steps {
+		commit, ok := tracker.completed(msgs[step.complete])
+		switch {
+		case step.commit < 0 && ok:
+			t.Fatalf("completing %d: unexpected commit of %d", step.complete, commit.Offset)
+		case step.commit >= 0 && (!ok || commit.Offset != step.commit):
+			t.Fatalf("completing %d: got commit %d (%v), want %d", step.complete, commit.Offset, ok, step.commit)
+		}
+	}
+}
+
+func TestConsumerKeepsOrderPerKey(t *testing.T) {
+	broker := memory.New(2)
+	var msgs []*testMessage
+	for seq := range 20 {
+		msgs = append(msgs, &testMessage{Author: []string{"a", "b", "c", "d"}[seq%4], Seq: seq})
+	}
+	publishTestMessages(t, broker, "events", msgs...)
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]int)
+		done = make(chan struct{})
+	)
+	processor := processorFunc(func(ctx context.Context, event *messaging.Event[*testMessage]) error {
+		msg := event.Data()
+		// Later messages finish first unless ordering holds them back.
+		time.Sleep(time.Duration(20-msg.Seq) * time.Millisecond / 4)
+		mu.Lock()
+		defer mu.Unlock()
+		seen[msg.Author] = append(seen[msg.Author], msg.Seq)
+		if len(seen["a"])+len(seen["b"])+len(seen["c"])+len(seen["d"]) == len(msgs) {
+			close(done)
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := PoolConfig{Concurrency: 4, Ordering: OrderByKey, DrainTimeout: time.Second}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		newTestConsumer(broker, processor, pool).Start(ctx)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for messages")
+	}
+	cancel()
+	<-stopped
+
+	for author, seqs := range seen {
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] < seqs[i-1] {
+				t.Fatalf("author %s processed out of order: %v", author, seqs)
+			}
+		}
+	}
+}
+
+func TestConsumerDrainsInFlightMessagesOnStop(t *testing.T) {
+	broker := memory.New(1)
+	publishTestMessages(t, broker, "events",
+		&testMessage{Author: "a", Seq: 0},
+		&testMessage{Author: "a", Seq: 1},
+		&testMessage{Author: "a", Seq: 2},
+	)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	processor := processorFunc(func(ctx context.Context, event *messaging.Event[*testMessage]) error {
+		if event.Data().Seq == 0 {
+			close(started)
+			<-release
+		}
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := PoolConfig{Concurrency: 1, Ordering: OrderByPartition, DrainTimeout: time.Second}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		newTestConsumer(broker, processor, pool).Start(ctx)
+	}()
+
+	<-started
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("consumer stopped before its in-flight message finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("consumer did not stop after draining")
+	}
+
+	// The in-flight message was committed; the queued ones were not started
+	// and are delivered again.
+	next := broker.Subscribe(transport.SubscriptionConfig{Topic: "events", GroupID: "workers"})
+	fetchCtx, fetchCancel := context.WithTimeout(context.Background(), time.Second)
+	defer fetchCancel()
+	msg, err := next.Fetch(fetchCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Offset != 1 {
+		t.Fatalf("resumed at offset %d, want 1", msg.Offset)
+	}
+}
+
+type batchProcessor struct {
+	processorFunc
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (p *batchProcessor) ProcessBatch(ctx context.Context, events []*messaging.Event[*testMessage]) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var seqs []int
+	for _, event := range events {
+		seqs = append(seqs, event.Data().Seq)
+	}
+	p.batches = append(p.batches, seqs)
+	return nil
+}
+
+func TestConsumerProcessesBatchesAndCommitsThem(t *testing.T) {
+	broker := memory.New(1)
+	var msgs []*testMessage
+	for seq := range 10 {
+		msgs = append(msgs, &testMessage{Author: "a", Seq: seq})
+	}
+	publishTestMessages(t, broker, "events", msgs...)
+
+	processor := &batchProcessor{processorFunc: func(ctx context.Context, event *messaging.Event[*testMessage]) error {
+		t.Errorf("event %d processed outside a batch", event.Data().Seq)
+		return nil
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := PoolConfig{Concurrency: 1, Ordering: OrderByPartition, DrainTimeout: time.Second, BatchSize: 4, BatchTimeout: 50 * time.Millisecond}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		newTestConsumer(broker, processor, pool).Start(ctx)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		processor.mu.Lock()
+		n := 0
+		for _, batch := range processor.batches {
+			n += len(batch)
+		}
+		processor.mu.Unlock()
+		if n == len(msgs) {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timed out with %d of %d messages processed", n, len(msgs))
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-stopped
+
+	next := 0
+	for _, batch := range processor.batches {
+		if len(batch) > pool.BatchSize {
+			t.Fatalf("batch %v larger than %d", batch, pool.BatchSize)
+		}
+		for _, seq := range batch {
+			if seq != next {
+				t.Fatalf("batches %v out of order", processor.batches)
+			}
+			next++
+		}
+	}
+	if len(processor.batches) == len(msgs) {
+		t.Fatalf("no events were batched: %v", processor.batches)
+	}
+
+	_, last, _ := broker.Offsets(context.Background(), "events", 0)
+	resumed := broker.Subscribe(transport.SubscriptionConfig{Topic: "events", GroupID: "workers"})
+	fetchCtx, fetchCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer fetchCancel()
+	if msg, err := resumed.Fetch(fetchCtx); err == nil {
+		t.Fatalf("offset %d of %d was not committed", msg.Offset, last)
+	}
+}
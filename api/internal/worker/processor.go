@@ -11,16 +11,47 @@ type Processor[T messaging.Eventable] interface {
 	Process(ctx context.Context, event *messaging.Event[T]) error
 }
 
+// BatchProcessor is a Processor that can also handle several events at once.
+// When a batch fails the consumer processes its events one by one, so both
+// methods must be idempotent.
+type BatchProcessor[T messaging.Eventable] interface {
+	Processor[T]
+	ProcessBatch(ctx context.Context, events []*messaging.Event[T]) error
+}
+
+// DeadLetterProcessor is a Processor that is told about the events it failed
+// to process once they are dead-lettered, after every retry stage.
+type DeadLetterProcessor[T messaging.Eventable] interface {
+	Processor[T]
+	DeadLettered(ctx context.Context, event *messaging.Event[T], cause error) error
+}
+
+type ProcessorOption[T Eventable] func(*DatabaseProcessor[T])
+
+// WithFanOut runs fanOut for every message after it has been saved.
+func WithFanOut[T Eventable](fanOut FanOut[T]) ProcessorOption[T] {
+	return func(p *DatabaseProcessor[T]) {
+		p.fanOut = fanOut
+	}
+}
+
+// DatabaseProcessor persists messages. The processed event is written to the
+// outbox in the same transaction and published by the outbox relay.
 type DatabaseProcessor[T Eventable] struct {
-	producer Producer[T]
-	repo     Repository[T]
+	repo   Repository[T]
+	fanOut FanOut[T]
 }
 
-func NewDatabaseProcessor[T Eventable](repo Repository[T], producer Producer[T]) *DatabaseProcessor[T] {
-	return &DatabaseProcessor[T]{
-		repo:     repo,
-		producer: producer,
+func NewDatabaseProcessor[T Eventable](repo Repository[T], options ...ProcessorOption[T]) *DatabaseProcessor[T] {
+	p := &DatabaseProcessor[T]{
+		repo: repo,
+	}
+
+	for _, opt := range options {
+		opt(p)
 	}
+
+	return p
 }
 
 func (p *DatabaseProcessor[T]) Process(ctx context.Context, event *messaging.Event[T]) error {
@@ -32,12 +63,74 @@ func (p *DatabaseProcessor[T]) Process(ctx context.Context, event *messaging.Eve
 			return err
 		}
 
-		if err := p.producer.Publish(ctx, msg); err != nil {
-			log.Printf("Failed to publish message: %v", err)
-			return err
+		if p.fanOut != nil {
+			if err := p.fanOut.FanOut(ctx, msg); err != nil {
+				log.Printf("Failed to fan out message: %v", err)
+				return err
+			}
 		}
 
 		log.Println("Successfully processed message")
 		return nil
 	})
 }
+
+// DeadLettered marks the message as failed. Until then it stays queued, since
+// a retry stage may still save it.
+func (p *DatabaseProcessor[T]) DeadLettered(ctx context.Context, event *messaging.Event[T], cause error) error {
+	return event.Process(ctx, func(ctx context.Context, msg T) error {
+		return p.repo.MarkFailed(ctx, msg, cause.Error())
+	})
+}
+
+// ProcessBatch saves the messages of events in one transaction, then fans out
+// each of them.
+func (p *DatabaseProcessor[T]) ProcessBatch(ctx context.Context, events []*messaging.Event[T]) error {
+	log.Printf("Processor received %d messages. Saving to database...", len(events))
+
+	msgs := make([]T, 0, len(events))
+	for _, event := range events {
+		msgs = append(msgs, event.Data())
+	}
+	if err := p.repo.SaveMessages(ctx, msgs); err != nil {
+		log.Printf("Failed to save messages: %v", err)
+		return err
+	}
+
+	if p.fanOut != nil {
+		for _, msg := range msgs {
+			if err := p.fanOut.FanOut(ctx, msg); err != nil {
+				log.Printf("Failed to fan out message: %v", err)
+				return err
+			}
+		}
+	}
+
+	log.Printf("Successfully processed %d messages", len(events))
+	return nil
+}
+
+// ChangeProcessor applies changes to saved messages, such as deletions and
+// edits. Like new messages, the processed event is written to the outbox in
+// the same transaction as the change.
+type ChangeProcessor[T Eventable] struct {
+	repo ChangeRepository[T]
+}
+
+func NewChangeProcessor[T Eventable](repo ChangeRepository[T]) *ChangeProcessor[T] {
+	return &ChangeProcessor[T]{
+		repo: repo,
+	}
+}
+
+func (p *ChangeProcessor[T]) Process(ctx context.Context, event *messaging.Event[T]) error {
+	log.Printf("Processor received %s event. Applying change...", event.Type())
+
+	if err := event.Process(ctx, p.repo.ApplyChange); err != nil {
+		log.Printf("Failed to apply change: %v", err)
+		return err
+	}
+
+	log.Println("Successfully applied change")
+	return nil
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"feed-api/internal/messaging"
+	"testing"
+)
+
+// fakeMessages fails every save and records the messages marked failed.
+type fakeMessages struct {
+	failed []string
+}
+
+func (r *fakeMessages) SaveMessage(ctx context.Context, msg *testMessage) error {
+	return errProcessing
+}
+
+func (r *fakeMessages) SaveMessages(ctx context.Context, msgs []*testMessage) error {
+	return errProcessing
+}
+
+func (r *fakeMessages) MarkFailed(ctx context.Context, msg *testMessage, reason string) error {
+	r.failed = append(r.failed, msg.Author+": "+reason)
+	return nil
+}
+
+func TestDatabaseProcessorMarksMessagesFailedOnlyWhenDeadLettered(t *testing.T) {
+	repo := &fakeMessages{}
+	processor := NewDatabaseProcessor[*testMessage](repo)
+	event := messaging.NewEventMessage(&testMessage{Author: "a"})
+
+	if err := processor.Process(context.Background(), event); err == nil {
+		t.Fatal("got no error from a failing save")
+	}
+	if len(repo.failed) != 0 {
+		t.Fatalf("marked %v failed while retries are pending", repo.failed)
+	}
+
+	if err := processor.DeadLettered(context.Background(), event, errProcessing); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a: " + errProcessing.Error(); len(repo.failed) != 1 || repo.failed[0] != want {
+		t.Fatalf("marked %v failed, want %q", repo.failed, want)
+	}
+}
+
+func TestRouterHandsDeadLettersToRoutedProcessor(t *testing.T) {
+	repo := &fakeMessages{}
+	router := NewRouter()
+	Route[*testMessage](router, NewDatabaseProcessor[*testMessage](repo), messaging.DefaultEventType)
+
+	event := messaging.NewEventMessage[messaging.Eventable](&testMessage{Author: "a"})
+	if err := router.DeadLettered(context.Background(), event, errProcessing); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.failed) != 1 {
+		t.Fatalf("marked %v failed, want the routed message", repo.failed)
+	}
+}
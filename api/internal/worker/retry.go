@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RetryPolicy controls how the worker retries events that fail processing.
+// Each stage first retries in process with exponential backoff. If all attempts
+// fail, the event is forwarded to the next retry topic, which is consumed after
+// its delay, and finally to the dead-letter topic.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	RetryDelays    []time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		RetryDelays:    []time.Duration{10 * time.Second, time.Minute},
+	}
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// RetryTopic names the retry topic of the given stage, counting from 1 in the
+// order of RetryPolicy.RetryDelays. Naming topics by position rather than by
+// delay keeps the names easy to reproduce outside Go; the delay an event
+// waits for travels in its retry-at header.
+func RetryTopic(topic string, stage int) string {
+	return fmt.Sprintf("%s-retry-%d", topic, stage)
+}
+
+func DeadLetterTopic(topic string) string {
+	return topic + "-dlq"
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
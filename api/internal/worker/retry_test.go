@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoffDoublesUpToMax(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 500 * time.Millisecond}
+	want := []time.Duration{100, 200, 400, 500, 500}
+	for i, w := range want {
+		if got := policy.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("attempt %d: got %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+}
+
+// The topic names are also derived by scripts/create-kafka-topics.sh.
+func TestRetryTopicNamesStagesByPosition(t *testing.T) {
+	if got := RetryTopic("events", 1); got != "events-retry-1" {
+		t.Errorf("RetryTopic(1) = %s, want events-retry-1", got)
+	}
+	if got := RetryTopic("events", 12); got != "events-retry-12" {
+		t.Errorf("RetryTopic(12) = %s, want events-retry-12", got)
+	}
+	if got := DeadLetterTopic("events"); got != "events-dlq" {
+		t.Errorf("DeadLetterTopic = %s, want events-dlq", got)
+	}
+}
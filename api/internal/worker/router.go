@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"feed-api/internal/messaging"
+	"fmt"
+)
+
+// ErrNoRoute is returned for events of a type no processor was routed for.
+var ErrNoRoute = errors.New("no processor for event type")
+
+// Router processes a topic carrying several event types, handing each event
+// to the processor routed for its type.
+type Router struct {
+	routes map[string]*route
+}
+
+type route struct {
+	process func(ctx context.Context, event *messaging.Event[messaging.Eventable]) error
+	// processBatch is nil when the processor does not handle batches.
+	processBatch func(ctx context.Context, events []*messaging.Event[messaging.Eventable]) error
+	// deadLettered is nil when the processor is not told about dead letters.
+	deadLettered func(ctx context.Context, event *messaging.Event[messaging.Eventable], cause error) error
+}
+
+func NewRouter() *Router {
+	return &Router{
+		routes: make(map[string]*route),
+	}
+}
+
+// Route hands events of the given types to processor. Their data must decode
+// to a T.
+func Route[T messaging.Eventable](r *Router, processor Processor[T], eventTypes ...string) {
+	rt := &route{
+		process: func(ctx context.Context, event *messaging.Event[messaging.Eventable]) error {
+			typed, err := as[T](event)
+			if err != nil {
+				return err
+			}
+			return processor.Process(ctx, typed)
+		},
+	}
+	if batcher, ok := processor.(BatchProcessor[T]); ok {
+		rt.processBatch = func(ctx context.Context, events []*messaging.Event[messaging.Eventable]) error {
+			typed := make([]*messaging.Event[T], 0, len(events))
+			for _, event := range events {
+				e, err := as[T](event)
+				if err != nil {
+					return err
+				}
+				typed = append(typed, e)
+			}
+			return batcher.ProcessBatch(ctx, typed)
+		}
+	}
+	if dl, ok := processor.(DeadLetterProcessor[T]); ok {
+		rt.deadLettered = func(ctx context.Context, event *messaging.Event[messaging.Eventable], cause error) error {
+			typed, err := as[T](event)
+			if err != nil {
+				return err
+			}
+			return dl.DeadLettered(ctx, typed, cause)
+		}
+	}
+
+	for _, eventType := range eventTypes {
+		r.routes[eventType] = rt
+	}
+}
+
+func as[T messaging.Eventable](event *messaging.Event[messaging.Eventable]) (*messaging.Event[T], error) {
+	typed, ok := messaging.As[T](event)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s event holds %T", messaging.ErrUnexpectedEvent, event.Type(), event.Data())
+	}
+	return typed, nil
+}
+
+func (r *Router) route(event *messaging.Event[messaging.Eventable]) (*route, error) {
+	rt, ok := r.routes[event.Type()]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrNoRoute, event.Type())
+	}
+	return rt, nil
+}
+
+func (r *Router) Process(ctx context.Context, event *messaging.Event[messaging.Eventable]) error {
+	rt, err := r.route(event)
+	if err != nil {
+		return err
+	}
+	return rt.process(ctx, event)
+}
+
+// DeadLettered tells the processor routed for the event that it was
+// dead-lettered, if that processor wants to know.
+func (r *Router) DeadLettered(ctx context.Context, event *messaging.Event[messaging.Eventable], cause error) error {
+	rt, err := r.route(event)
+	if err != nil || rt.deadLettered == nil {
+		return nil
+	}
+	return rt.deadLettered(ctx, event, cause)
+}
+
+// ProcessBatch splits events into runs of the same route, keeping their order,
+// and processes each run as a batch when its processor supports it.
+func (r *Router) ProcessBatch(ctx context.Context, events []*messaging.Event[messaging.Eventable]) error {
+	for len(events) > 0 {
+		rt, err := r.route(events[0])
+		if err != nil {
+			return err
+		}
+		n := 1
+		for n < len(events) {
+			next, err := r.route(events[n])
+			if err != nil || next != rt {
+				break
+			}
+			n++
+		}
+
+		run := events[:n]
+		events = events[n:]
+		if rt.processBatch != nil {
+			if err = rt.processBatch(ctx, run); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, event := range run {
+			if err = rt.process(ctx, event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -4,14 +4,26 @@ import "context"
 
 type Repository[T any] interface {
 	SaveMessage(ctx context.Context, msg T) error
-	GetAllMessages(ctx context.Context) ([]T, error)
+	SaveMessages(ctx context.Context, msgs []T) error
+	MarkFailed(ctx context.Context, msg T, reason string) error
+}
+
+type ChangeRepository[T any] interface {
+	ApplyChange(ctx context.Context, change T) error
 }
 
 type Eventable interface {
 	MarshalJSON() ([]byte, error)
 }
 
-type Producer[T any] interface {
-	Publish(ctx context.Context, data T) error
-	Close() error
+type Authored interface {
+	Eventable
+	UserID() string
+}
+
+type TimelineRepository[T any] interface {
+	CountFollowers(ctx context.Context, userID string) (int, error)
+	GetFollowerIDs(ctx context.Context, userID, after string, limit int) ([]string, error)
+	AddTimelineEntries(ctx context.Context, followerIDs []string, msg T) error
+	MarkFannedOut(ctx context.Context, msg T) error
 }
@@ -2,31 +2,73 @@ package worker
 
 import (
 	"context"
+	"feed-api/internal/transport"
 	"log"
 	"sync"
+	"time"
 )
 
 type Worker[T Eventable] struct {
-	consumer Consumer
-	wg       sync.WaitGroup
+	consumers []Consumer
+	forwarder *Forwarder
+	wg        sync.WaitGroup
 }
 
-func NewWorker[T Eventable](brokers []string, topic, groupID string, processor Processor[T]) *Worker[T] {
-	consumer := NewConsumer(brokers, topic, groupID, processor)
+// NewWorker consumes topic and, for every delay in the retry policy, the
+// matching retry topic, each with its own pool of lanes. Events that exhaust
+// the policy end up in the dead-letter topic.
+func NewWorker[T Eventable](t transport.Transport, topic, groupID string, processor Processor[T], policy RetryPolicy, pool PoolConfig) *Worker[T] {
+	forwarder := NewForwarder(t.NewPublisher(transport.PublisherConfig{Partitioning: transport.PartitionByKey}))
+	deadLetter := DeadLetterTopic(topic)
+
+	topics := []string{topic}
+	delays := []time.Duration{0}
+	for i, delay := range policy.RetryDelays {
+		topics = append(topics, RetryTopic(topic, i+1))
+		delays = append(delays, delay)
+	}
+
+	consumers := make([]Consumer, 0, len(topics))
+	for i := range topics {
+		s := stage{
+			topic:      topics[i],
+			delay:      delays[i],
+			next:       deadLetter,
+			deadLetter: deadLetter,
+		}
+		if i+1 < len(topics) {
+			s.next = topics[i+1]
+			s.nextDelay = delays[i+1]
+		}
+		subscription := t.Subscribe(transport.SubscriptionConfig{
+			Topic:   s.topic,
+			GroupID: groupID,
+		})
+		consumers = append(consumers, NewConsumer(subscription, processor, policy, pool, forwarder, s))
+	}
+
 	return &Worker[T]{
-		consumer: consumer,
+		consumers: consumers,
+		forwarder: forwarder,
 	}
 }
 
 func (w *Worker[T]) Start(ctx context.Context) {
-	w.wg.Add(1)
-	go func() {
-		defer w.wg.Done()
-		w.consumer.Start(ctx)
-	}()
+	for _, consumer := range w.consumers {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			consumer.Start(ctx)
+		}()
+	}
 }
 
+// Stop waits for the consumers to drain after the context passed to Start is
+// done, then closes the forwarder.
 func (w *Worker[T]) Stop() {
 	w.wg.Wait()
+	if err := w.forwarder.Close(); err != nil {
+		log.Printf("Error closing forwarder: %v", err)
+	}
 	log.Println("Worker stopped")
 }
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS messages@messages_created_at_id_idx;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS messages_created_at_id_idx ON messages (created_at DESC, id DESC);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS messages@messages_user_id_created_at_id_idx;
+DROP TABLE IF EXISTS follows;
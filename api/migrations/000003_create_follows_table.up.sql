@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS follows (
+        follower_id STRING NOT NULL,
+        followee_id STRING NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        PRIMARY KEY (follower_id, followee_id),
+        INDEX follows_followee_id_idx (followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS messages_user_id_created_at_id_idx ON messages (user_id, created_at DESC, id DESC);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS follower_counts;
+DROP TABLE IF EXISTS timeline_entries;
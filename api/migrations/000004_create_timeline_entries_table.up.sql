@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS timeline_entries (
+        user_id STRING NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL,
+        message_id UUID NOT NULL,
+        author_id STRING NOT NULL,
+        PRIMARY KEY (user_id, created_at DESC, message_id DESC),
+        INDEX timeline_entries_user_id_author_id_idx (user_id, author_id)
+);
+
+CREATE TABLE IF NOT EXISTS follower_counts (
+        user_id STRING PRIMARY KEY,
+        followers INT8 NOT NULL DEFAULT 0
+);
+
+INSERT INTO follower_counts (user_id, followers)
+SELECT followee_id, count(*) FROM follows GROUP BY followee_id
+ON CONFLICT (user_id) DO NOTHING;
+
+INSERT INTO timeline_entries (user_id, created_at, message_id, author_id)
+SELECT f.follower_id, m.created_at, m.id, m.user_id
+FROM follows AS f JOIN messages AS m ON m.user_id = f.followee_id
+ON CONFLICT DO NOTHING;
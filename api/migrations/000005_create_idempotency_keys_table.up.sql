@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+        user_id STRING NOT NULL,
+        idempotency_key STRING NOT NULL,
+        message_id UUID NOT NULL,
+        content STRING NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL,
+        expires_at TIMESTAMPTZ NOT NULL,
+        PRIMARY KEY (user_id, idempotency_key)
+) WITH (ttl_expiration_expression = 'expires_at', ttl_job_cron = '@hourly');
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS message_deliveries;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS message_deliveries (
+        id UUID PRIMARY KEY,
+        user_id STRING NOT NULL,
+        status STRING NOT NULL,
+        error STRING,
+        created_at TIMESTAMPTZ NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+) WITH (ttl_expiration_expression = 'updated_at + INTERVAL ''7 days''', ttl_job_cron = '@daily');
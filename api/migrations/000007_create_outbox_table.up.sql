@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS outbox (
+        id STRING PRIMARY KEY,
+        topic STRING NOT NULL,
+        payload BYTES NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        published_at TIMESTAMPTZ,
+        INDEX outbox_pending_idx (created_at) WHERE published_at IS NULL
+) WITH (ttl_expiration_expression = 'published_at + INTERVAL ''1 day''', ttl_job_cron = '@hourly');
@@ -0,0 +1 @@
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS partition_key STRING NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS seq INT8 NOT NULL DEFAULT 0;
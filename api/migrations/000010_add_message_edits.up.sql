@@ -0,0 +1,10 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
+
+CREATE TABLE IF NOT EXISTS message_edits (
+        id UUID PRIMARY KEY,
+        message_id UUID NOT NULL,
+        previous_content STRING NOT NULL,
+        edited_at TIMESTAMPTZ NOT NULL,
+        INDEX message_edits_message_id_idx (message_id, edited_at)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS in_reply_to;
+DROP INDEX IF EXISTS messages@messages_conversation_id_idx;
+ALTER TABLE messages DROP COLUMN IF EXISTS reply_count;
+ALTER TABLE messages DROP COLUMN IF EXISTS depth;
+ALTER TABLE messages DROP COLUMN IF EXISTS conversation_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS in_reply_to;
@@ -0,0 +1,10 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS in_reply_to UUID NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS conversation_id UUID NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS depth INT8 NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_count INT8 NOT NULL DEFAULT 0;
+
+UPDATE messages SET conversation_id = id WHERE conversation_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS messages_conversation_id_idx ON messages (conversation_id, created_at, id);
+
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS in_reply_to UUID NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS repost_count;
+ALTER TABLE messages DROP COLUMN IF EXISTS like_count;
+DROP TABLE IF EXISTS reposts;
+DROP TABLE IF EXISTS likes;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS likes (
+        message_id UUID NOT NULL,
+        user_id STRING NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        PRIMARY KEY (message_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS reposts (
+        message_id UUID NOT NULL,
+        user_id STRING NOT NULL,
+        id UUID NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        PRIMARY KEY (message_id, user_id),
+        INDEX reposts_user_id_idx (user_id, created_at DESC)
+);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS like_count INT8 NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS repost_count INT8 NOT NULL DEFAULT 0;
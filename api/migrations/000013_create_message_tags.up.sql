@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS message_hashtags (
+        tag STRING NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL,
+        message_id UUID NOT NULL,
+        PRIMARY KEY (tag, created_at DESC, message_id DESC)
+);
+
+CREATE TABLE IF NOT EXISTS message_mentions (
+        user_id STRING NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL,
+        message_id UUID NOT NULL,
+        author_id STRING NOT NULL,
+        PRIMARY KEY (user_id, created_at DESC, message_id DESC)
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS messages@messages_content_trgm_idx;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS message_mentions@message_mentions_message_id_idx;
+DROP INDEX IF EXISTS message_hashtags@message_hashtags_message_id_idx;
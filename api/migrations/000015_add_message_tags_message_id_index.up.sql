@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS message_hashtags_message_id_idx ON message_hashtags (message_id);
+CREATE INDEX IF NOT EXISTS message_mentions_message_id_idx ON message_mentions (message_id);
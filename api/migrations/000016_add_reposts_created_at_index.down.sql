@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS reposts_user_id_idx ON reposts (user_id, created_at DESC);
+DROP INDEX IF EXISTS reposts@reposts_user_id_created_at_id_idx;
+DROP INDEX IF EXISTS reposts@reposts_created_at_id_idx;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS messages@messages_not_fanned_out_idx;
+ALTER TABLE messages DROP COLUMN IF EXISTS fanned_out;
@@ -0,0 +1,7 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS fanned_out BOOL NOT NULL DEFAULT false;
+
+-- Existing messages stay unmarked, whatever fan-out threshold wrote them: home
+-- timelines merge them at read time, and the ones that were also fanned out
+-- are de-duplicated with their timeline entries.
+
+CREATE INDEX IF NOT EXISTS messages_not_fanned_out_idx ON messages (user_id, created_at DESC, id DESC) WHERE NOT fanned_out;
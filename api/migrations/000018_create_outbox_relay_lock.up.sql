@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS outbox_relay_lock (
+        id INT8 PRIMARY KEY
+);
+INSERT INTO outbox_relay_lock (id) VALUES (1) ON CONFLICT DO NOTHING;
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"feed-bot/internal/auth"
 	"feed-bot/internal/bot"
 	"feed-bot/internal/client"
 	"feed-bot/internal/generator"
@@ -15,6 +17,7 @@ import (
 const (
 	UserCount     = 3
 	SleepInterval = 10 * time.Second
+	TokenTTL      = 5 * time.Minute
 )
 
 func main() {
@@ -24,15 +27,26 @@ func main() {
 	APIEndpoint := fmt.Sprintf("http://%s:%s/api/messages",
 		os.Getenv("API_HOST"), os.Getenv("API_PORT"))
 
+	authSecret, err := base64.StdEncoding.DecodeString(os.Getenv("AUTH_KEY"))
+	if err != nil || len(authSecret) == 0 {
+		log.Println("AUTH_KEY must be a base64-encoded secret")
+		return
+	}
+	signer := auth.NewSigner(os.Getenv("AUTH_KEY_ID"), authSecret, TokenTTL)
+
 	messageGenerator := generator.NewMessageGenerator(UserCount)
 	messageFactory := bot.NewMessageFactory()
-	httpClient := client.NewHTTPClient[*bot.Message](APIEndpoint)
+	httpClient := client.NewHTTPClient[*bot.Message](APIEndpoint,
+		client.WithBearerToken(func(m *bot.Message) (string, error) {
+			return signer.Sign(m.UserID())
+		}),
+	)
 
 	messageBot := bot.NewBot[*bot.Message](messageGenerator, messageFactory, httpClient)
 
 	taskScheduler := scheduler.NewScheduler(ctx, cancel, SleepInterval, messageBot)
 
-	err := taskScheduler.Run()
+	err = taskScheduler.Run()
 	if err != nil {
 		log.Printf("task scheduler error: %s", err)
 	}
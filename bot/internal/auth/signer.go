@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// Signer mints short-lived HS256 tokens so the bot can post as any of its users.
+type Signer struct {
+	keyID  string
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewSigner(keyID string, secret []byte, ttl time.Duration) *Signer {
+	return &Signer{
+		keyID:  keyID,
+		secret: secret,
+		ttl:    ttl,
+	}
+}
+
+func (s *Signer) Sign(userID string) (string, error) {
+	type Header struct {
+		Algorithm string `json:"alg"`
+		Type      string `json:"typ"`
+		KeyID     string `json:"kid,omitempty"`
+	}
+	type Claims struct {
+		Subject   string `json:"sub"`
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+	}
+
+	header, err := json.Marshal(Header{Algorithm: "HS256", Type: "JWT", KeyID: s.keyID})
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims, err := json.Marshal(Claims{
+		Subject:   userID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(signingInput))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -20,13 +20,17 @@ type Message struct {
 	content string
 }
 
+// UserID is the author the message is posted as. It is not part of the
+// payload; the API takes the author from the bearer token.
+func (m *Message) UserID() string {
+	return m.userID
+}
+
 func (m *Message) MarshalJSON() ([]byte, error) {
 	type Payload struct {
-		UserID  string `json:"user_id"`
 		Content string `json:"content"`
 	}
 	encodedPayload := Payload{
-		UserID:  m.userID,
 		Content: m.content,
 	}
 
@@ -9,16 +9,32 @@ import (
 	"net/http"
 )
 
+type Option[T Sendable] func(*HTTPClient[T])
+
+// WithBearerToken authenticates each request with the token returned for its payload.
+func WithBearerToken[T Sendable](token func(payload T) (string, error)) Option[T] {
+	return func(c *HTTPClient[T]) {
+		c.token = token
+	}
+}
+
 type HTTPClient[T Sendable] struct {
 	endpoint   string
 	httpClient *http.Client
+	token      func(payload T) (string, error)
 }
 
-func NewHTTPClient[T Sendable](endpoint string) *HTTPClient[T] {
-	return &HTTPClient[T]{
+func NewHTTPClient[T Sendable](endpoint string, options ...Option[T]) *HTTPClient[T] {
+	c := &HTTPClient[T]{
 		endpoint:   endpoint,
 		httpClient: &http.Client{},
 	}
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	return c
 }
 
 func (c *HTTPClient[T]) Send(ctx context.Context, payload T) error {
@@ -34,6 +50,14 @@ func (c *HTTPClient[T]) Send(ctx context.Context, payload T) error {
 
 	req.Header.Set("Content-Type", "application/json")
 
+	if c.token != nil {
+		token, err := c.token(payload)
+		if err != nil {
+			return fmt.Errorf("failed to create token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
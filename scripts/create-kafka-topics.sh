@@ -3,25 +3,37 @@
 set -e
 
 BOOTSTRAP_SERVER="kafka:29092"
+SOURCE_TOPIC="events-to-process"
+# Must match the API's RETRY_TOPIC_DELAYS; an empty value disables retry topics.
+# Retry topics are named after their position in the list: -retry-1, -retry-2, ...
+RETRY_TOPIC_DELAYS="${RETRY_TOPIC_DELAYS-10s,1m}"
+
+create_topic() {
+  echo "Creating topic '$1'..."
+  kafka-topics \
+    --create \
+    --if-not-exists \
+    --bootstrap-server $BOOTSTRAP_SERVER \
+    --topic "$1" \
+    --partitions 3 \
+    --replication-factor 1
+}
 
 echo "Kafka is ready. Proceeding with topic creation..."
 
-echo "Creating topic 'events-to-process'..."
-kafka-topics \
-  --create \
-  --if-not-exists \
-  --bootstrap-server $BOOTSTRAP_SERVER \
-  --topic events-to-process \
-  --partitions 3 \
-  --replication-factor 1
+TOPICS=("$SOURCE_TOPIC" "events-processed")
+IFS=',' read -ra DELAYS <<< "$RETRY_TOPIC_DELAYS"
+stage=0
+for delay in "${DELAYS[@]}"; do
+  delay="${delay// /}"
+  [[ -z "$delay" ]] && continue
+  stage=$((stage + 1))
+  TOPICS+=("$SOURCE_TOPIC-retry-$stage")
+done
+TOPICS+=("$SOURCE_TOPIC-dlq")
 
-echo "Creating topic 'events-processed'..."
-kafka-topics \
-  --create \
-  --if-not-exists \
-  --bootstrap-server $BOOTSTRAP_SERVER \
-  --topic events-processed \
-  --partitions 3 \
-  --replication-factor 1
+for topic in "${TOPICS[@]}"; do
+  create_topic "$topic"
+done
 
-echo "All topics created successfully."
\ No newline at end of file
+echo "All topics created successfully."